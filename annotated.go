@@ -0,0 +1,44 @@
+package obs
+
+import "time"
+
+// An Annotated sample carries the time it was captured, and optionally a set of labels, alongside its value.
+// Sampling through SampleAnnotated records the capture time in the producer's goroutine, so that the sample function
+// can tell it apart from the time of processing, such as for rates unskewed by queueing, or to measure queueing delay.
+type Annotated[T any] struct {
+	V        T
+	Captured time.Time
+	Labels   map[string]string // nil if none were given
+}
+
+// Delay returns the time elapsed since the sample was captured, according to the given Clock (the real one if nil).
+// Called by the sample function, this is the time the sample spent waiting in the queue.
+func (x Annotated[T]) Delay(clock Clock) time.Duration {
+	return clockOr(clock).Now().Sub(x.Captured)
+}
+
+// AnnotatedSamplerMake returns a Sampler of Annotated samples, with its Timestamp set to their capture time.
+func AnnotatedSamplerMake[S any, T any](queueSize int, sampleFunc func(*S, Annotated[T])) *Sampler[S, Annotated[T]] {
+	x := SamplerMake(queueSize, sampleFunc)
+	x.Timestamp = func(v Annotated[T]) time.Time {
+		return v.Captured
+	}
+	return x
+}
+
+// SampleAnnotated pushes a sample annotated with the current time according to the Sampler's Clock, and the given
+// labels, which may be nil. The labels must not be modified afterwards. Reports discards like SampleErr.
+func SampleAnnotated[S any, T any](x *Sampler[S, Annotated[T]], v T, labels map[string]string) error {
+	return SampleErr(x, Annotated[T]{
+		V:        v,
+		Captured: clockOr(x.Clock).Now(),
+		Labels:   labels,
+	})
+}
+
+// Unannotated adapts a sample function of plain values to Annotated samples, discarding the annotations.
+func Unannotated[S any, T any](sampleFunc func(*S, T)) func(*S, Annotated[T]) {
+	return func(s *S, v Annotated[T]) {
+		sampleFunc(s, v.V)
+	}
+}
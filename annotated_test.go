@@ -0,0 +1,62 @@
+package obs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestSampleAnnotated(t *testing.T) {
+	start := time.Unix(100, 0)
+	clock := obstest.ClockMake(start)
+	type seen struct {
+		v        int
+		captured time.Time
+		labels   map[string]string
+	}
+	x := obs.AnnotatedSamplerMake(4, func(s *[]seen, v obs.Annotated[int]) {
+		*s = append(*s, seen{v.V, v.Captured, v.Labels})
+	})
+	x.Clock = clock
+	obs.Start(x)
+
+	if err := obs.SampleAnnotated(x, 1, map[string]string{"route": "/"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	obs.SampleAnnotated(x, 2, nil)
+	got := obs.StopAndCollect(x)
+
+	want := []seen{
+		{1, start, map[string]string{"route": "/"}},
+		{2, start.Add(time.Second), nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := obs.Stats(x).OutOfOrder; n != 0 {
+		t.Errorf("out of order: got %d", n)
+	}
+}
+
+func TestAnnotatedDelay(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	v := obs.Annotated[int]{V: 1, Captured: clock.Now()}
+	clock.Advance(3 * time.Second)
+	if d := v.Delay(clock); d != 3*time.Second {
+		t.Errorf("got %v, want 3s", d)
+	}
+}
+
+func TestUnannotated(t *testing.T) {
+	x := obs.AnnotatedSamplerMake(4, obs.Unannotated(func(s *int, v int) { *s += v }))
+	obs.Start(x)
+	obs.SampleAnnotated(x, 2, nil)
+	obs.SampleAnnotated(x, 3, map[string]string{"ignored": "yes"})
+	if got := obs.StopAndCollect(x); got != 5 {
+		t.Errorf("got %d, want 5", got)
+	}
+}
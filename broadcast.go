@@ -0,0 +1,111 @@
+package obs
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A DropPolicy chooses which value is discarded when a buffer is full.
+type DropPolicy int
+
+const (
+	DropNewest DropPolicy = iota // discard the incoming value
+	DropOldest                   // discard the oldest buffered value to make room
+)
+
+// A Broadcaster fans out published values to a changing set of subscribers.
+// Each subscriber has its own buffer, delivery goroutine and DropPolicy, so that a slow subscriber only loses its own values,
+// and never blocks the publisher or the other subscribers.
+//
+// Its methods are concurrent safe.
+type Broadcaster[T any] struct {
+	subs map[*Subscription[T]]struct{}
+	mux  sync.RWMutex // read locked by publishers
+}
+
+// A Subscription is a subscriber's membership in a Broadcaster.
+type Subscription[T any] struct {
+	ch      chan T
+	policy  DropPolicy
+	dropped atomic.Uint64
+	done    chan struct{} // closed when the delivery goroutine exits
+}
+
+func BroadcasterMake[T any]() *Broadcaster[T] {
+	return &Broadcaster[T]{
+		subs: make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// Publish offers a value to all current subscribers, without blocking.
+func (x *Broadcaster[T]) Publish(v T) {
+	x.mux.RLock()
+	for s := range x.subs {
+		s.offer(v)
+	}
+	x.mux.RUnlock()
+}
+
+// Subscribe starts delivering published values to fn, from a dedicated goroutine, through a buffer of the given size.
+// To feed a Sampler, pass its Sample method.
+func (x *Broadcaster[T]) Subscribe(bufferSize int, policy DropPolicy, fn func(T)) *Subscription[T] {
+	s := &Subscription[T]{
+		ch:     make(chan T, bufferSize),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		for v := range s.ch {
+			fn(v)
+		}
+	}()
+
+	x.mux.Lock()
+	x.subs[s] = struct{}{}
+	x.mux.Unlock()
+	return s
+}
+
+// Unsubscribe stops delivery to a subscriber, once it has received the values already in its buffer, and waits for it.
+// NoOp if the subscription has already ended.
+func (x *Broadcaster[T]) Unsubscribe(s *Subscription[T]) {
+	x.mux.Lock()
+	_, ok := x.subs[s]
+	if ok {
+		delete(x.subs, s)
+		close(s.ch)
+	}
+	x.mux.Unlock()
+
+	if ok {
+		<-s.done
+	}
+}
+
+// Dropped returns the number of values the subscriber lost to a full buffer.
+func (x *Subscription[T]) Dropped() uint64 {
+	return x.dropped.Load()
+}
+
+func (x *Subscription[T]) offer(v T) {
+	select {
+	case x.ch <- v:
+		return
+	default:
+	}
+
+	if x.policy == DropOldest {
+		select {
+		case <-x.ch:
+			x.dropped.Add(1)
+		default:
+		}
+		select {
+		case x.ch <- v:
+			return
+		default:
+		}
+	}
+	x.dropped.Add(1)
+}
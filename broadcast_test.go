@@ -0,0 +1,71 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBroadcasterJoinLeave(t *testing.T) {
+	x := BroadcasterMake[int]()
+	var a, b []int
+	subA := x.Subscribe(8, DropNewest, func(v int) { a = append(a, v) })
+	x.Publish(1)
+	x.Publish(2)
+	subB := x.Subscribe(8, DropNewest, func(v int) { b = append(b, v) })
+	x.Publish(3)
+	x.Unsubscribe(subA)
+	x.Publish(4)
+	x.Unsubscribe(subB)
+	x.Unsubscribe(subB) // NoOp
+	x.Publish(5)
+
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(a, want) {
+		t.Errorf("a: got %v, want %v", a, want)
+	}
+	if want := []int{3, 4}; !reflect.DeepEqual(b, want) {
+		t.Errorf("b: got %v, want %v", b, want)
+	}
+}
+
+func TestBroadcasterSlowSubscriber(t *testing.T) {
+	for _, c := range []struct {
+		policy DropPolicy
+		want   []int
+	}{
+		{DropNewest, []int{1, 2}},
+		{DropOldest, []int{1, 4}},
+	} {
+		x := BroadcasterMake[int]()
+		taken := make(chan struct{})
+		release := make(chan struct{})
+		var got []int
+		slow := x.Subscribe(1, c.policy, func(v int) {
+			if v == 1 {
+				close(taken)
+				<-release
+			}
+			got = append(got, v)
+		})
+		sum := summing(8)
+		Start(sum)
+		fast := x.Subscribe(8, DropNewest, sum.Sample)
+		x.Publish(1)
+		<-taken
+		for v := 2; v <= 4; v++ {
+			x.Publish(v) // never blocks, despite the stuck subscriber
+		}
+		close(release)
+		x.Unsubscribe(slow)
+		x.Unsubscribe(fast)
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("policy %d: got %v, want %v", c.policy, got, c.want)
+		}
+		if n := slow.Dropped(); n != 2 {
+			t.Errorf("policy %d: dropped %d, want 2", c.policy, n)
+		}
+		if got := StopAndCollect(sum); got != 10 {
+			t.Errorf("policy %d: fast subscriber got %d, want 10", c.policy, got)
+		}
+	}
+}
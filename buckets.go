@@ -0,0 +1,67 @@
+package obs
+
+import "time"
+
+// bucketRing splits a sliding time window into equal buckets, reusing each one as time advances past it.
+// Shared by SlidingWindow and RateSampler, which differ in what they aggregate per bucket.
+type bucketRing[B any] struct {
+	window  time.Duration
+	width   int64 // bucket duration, in nanoseconds
+	buckets []B
+	epochs  []int64 // absolute bucket index each bucket belongs to
+}
+
+// bucketRingMake returns a ring over the given window, made up of n buckets.
+func bucketRingMake[B any](window time.Duration, n int) bucketRing[B] {
+	n = max(n, 1)
+	return bucketRing[B]{
+		window:  window,
+		width:   max(int64(window)/int64(n), 1),
+		buckets: make([]B, n),
+		epochs:  make([]int64, n),
+	}
+}
+
+// valid reports whether the window can hold anything.
+func (x *bucketRing[B]) valid() bool {
+	return x.window > 0 && len(x.buckets) > 0
+}
+
+// epoch returns the absolute bucket index of the current time.
+func (x *bucketRing[B]) epoch(clock Clock) int64 {
+	return clockOr(clock).Now().UnixNano() / x.width
+}
+
+// at returns the bucket for epoch, cleared first if it still held an older one.
+func (x *bucketRing[B]) at(epoch int64) *B {
+	n := int64(len(x.buckets))
+	i := ((epoch % n) + n) % n
+	if x.epochs[i] != epoch {
+		x.epochs[i] = epoch
+		var zero B
+		x.buckets[i] = zero
+	}
+	return &x.buckets[i]
+}
+
+// each calls f with every bucket within the window ending at epoch.
+func (x *bucketRing[B]) each(epoch int64, f func(*B)) {
+	n := int64(len(x.buckets))
+	for i, e := range x.epochs {
+		if age := epoch - e; age >= 0 && age < n {
+			f(&x.buckets[i])
+		}
+	}
+}
+
+func (x *bucketRing[B]) clone() bucketRing[B] {
+	o := *x
+	o.buckets = append([]B(nil), x.buckets...)
+	o.epochs = append([]int64(nil), x.epochs...)
+	return o
+}
+
+func (x *bucketRing[B]) reset() {
+	clear(x.buckets)
+	clear(x.epochs)
+}
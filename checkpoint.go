@@ -0,0 +1,147 @@
+package obs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Checkpointer state provides its own persistent encoding, used by checkpoints instead of JSON.
+type Checkpointer interface {
+	Checkpoint(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// WriteCheckpoint encodes the Sampler's state to w, by its Checkpoint method if *S is a Checkpointer,
+// or as JSON otherwise. Safe to use while the Sampler is running: a state that is a Cloner is encoded from a clone,
+// any other is encoded while holding the state lock, holding up processing meanwhile.
+func WriteCheckpoint[S any, T any](x *Sampler[S, T], w io.Writer) error {
+	return encodeState(x, func(s *S) error {
+		if c, ok := any(s).(Checkpointer); ok {
+			return c.Checkpoint(w)
+		}
+		return json.NewEncoder(w).Encode(s)
+	})
+}
+
+// encodeState calls encode with a state that can't be modified concurrently:
+// a clone if the state is a Cloner, and otherwise the state itself, while holding its lock.
+// Shallow Snapshots would share maps and slices with the processing goroutine.
+func encodeState[S any, T any](x *Sampler[S, T], encode func(*S) error) error {
+	if _, ok := any(x.state).(Cloner[S]); ok {
+		s := Snapshot(x)
+		return encode(&s)
+	}
+
+	x.stateMux.Lock()
+	defer x.stateMux.Unlock()
+	return encode(x.state)
+}
+
+// ReadCheckpoint replaces the Sampler's state with one decoded from r, as written by WriteCheckpoint,
+// so that aggregation resumes where it left off, for example keeping counters monotonic across restarts.
+// Only allowed before Start; returns ErrStarted afterwards.
+func ReadCheckpoint[S any, T any](x *Sampler[S, T], r io.Reader) error {
+	if x.started.Load() {
+		return ErrStarted
+	}
+
+	var s S
+	var err error
+	if c, ok := any(&s).(Checkpointer); ok {
+		err = c.Restore(r)
+	} else {
+		err = json.NewDecoder(r).Decode(&s)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring checkpoint: %w", err)
+	}
+
+	x.stateMux.Lock()
+	*x.state = s
+	x.stateMux.Unlock()
+	return nil
+}
+
+// SetCheckpoint makes the processing goroutine write a checkpoint of the state every interval (using Clock),
+// and once more after the queue is drained on Stop, before Final. Each checkpoint is written to a new writer
+// obtained from open, which is closed afterwards; see CheckpointFile. Errors are reported through the package Logger.
+//
+// Checkpoints are encoded in the processing goroutine, holding up samples meanwhile, so the interval should be
+// generous for large states. Must be called before Start; returns ErrStarted afterwards.
+func SetCheckpoint[S any, T any](x *Sampler[S, T], interval time.Duration, open func() (io.WriteCloser, error)) error {
+	if x.started.Load() {
+		return ErrStarted
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid checkpoint interval: %v", interval)
+	}
+
+	x.checkpointInterval = interval
+	x.checkpointOpen = open
+	return nil
+}
+
+// checkpoint writes a checkpoint on behalf of the processing goroutine.
+func checkpoint[S any, T any](x *Sampler[S, T]) {
+	w, err := x.checkpointOpen()
+	if err == nil {
+		err = WriteCheckpoint(x, w)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		warn("obs: checkpoint failed", "err", err)
+	}
+}
+
+// CheckpointFile returns an open function for SetCheckpoint, that writes checkpoints to the file at path.
+// Each one is written to a temporary file in the same directory, which replaces the previous checkpoint
+// once closed, so that a checkpoint interrupted by a crash leaves the previous one intact.
+func CheckpointFile(path string) func() (io.WriteCloser, error) {
+	return func() (io.WriteCloser, error) {
+		f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		return &checkpointFile{f, path}, nil
+	}
+}
+
+type checkpointFile struct {
+	*os.File
+	path string
+}
+
+func (x *checkpointFile) Close() error {
+	err := x.File.Sync()
+	if closeErr := x.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(x.File.Name(), x.path)
+	}
+	if err != nil {
+		os.Remove(x.File.Name())
+	}
+	return err
+}
+
+// RestoreCheckpointFile is ReadCheckpoint from the file at path. A missing file is not an error,
+// as there is nothing to restore on the first run.
+func RestoreCheckpointFile[S any, T any](x *Sampler[S, T], path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ReadCheckpoint(x, f)
+}
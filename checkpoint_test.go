@@ -0,0 +1,132 @@
+package obs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func countingSampler() *Sampler[map[string]int, string] {
+	return SamplerMake(16, func(s *map[string]int, v string) {
+		if *s == nil {
+			*s = make(map[string]int)
+		}
+		(*s)[v]++
+	})
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	x := countingSampler()
+	Start(x)
+	Sample(x, "a")
+	Sample(x, "b")
+	StopAndWait(x)
+
+	var b bytes.Buffer
+	if err := WriteCheckpoint(x, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	y := countingSampler()
+	if err := ReadCheckpoint(y, &b); err != nil {
+		t.Fatal(err)
+	}
+	Start(y)
+	Sample(y, "a")
+	got := StopAndCollect(y)
+	if want := map[string]int{"a": 2, "b": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := ReadCheckpoint(y, &b); err != ErrStarted {
+		t.Errorf("restoring a started Sampler: got %v, want ErrStarted", err)
+	}
+}
+
+// TestCheckpointWhileRunning checks, under -race, that encoding a map state doesn't race with its processing.
+func TestCheckpointWhileRunning(t *testing.T) {
+	x := countingSampler()
+	Start(x)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			Sample(x, string(rune('a'+i%26)))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := WriteCheckpoint(x, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := MarshalState(x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	StopAndWait(x)
+}
+
+func TestCheckpointFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	x := countingSampler()
+	if err := RestoreCheckpointFile(x, path); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if err := SetCheckpoint(x, time.Hour, CheckpointFile(path)); err != nil {
+		t.Fatal(err)
+	}
+	Start(x)
+	Sample(x, "a")
+	StopAndWait(x)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(bytes.TrimSpace(data)); got != `{"a":1}` {
+		t.Errorf("checkpoint on Stop: got %s", got)
+	}
+
+	y := countingSampler()
+	if err := RestoreCheckpointFile(y, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := Snapshot(y); got["a"] != 1 {
+		t.Errorf("restored %v", got)
+	}
+}
+
+func TestMarshalStateContinuity(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	Sample(x, 1)
+	Sample(x, 2)
+	Flush(x)
+	data, err := MarshalState(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	StopAndWait(x)
+
+	y := summing(4)
+	if err := RestoreState(y, data); err != nil {
+		t.Fatal(err)
+	}
+	Start(y)
+	if err := RestoreState(y, data); err != ErrStarted {
+		t.Errorf("after Start: got %v, want ErrStarted", err)
+	}
+	Sample(y, 4)
+	if got := StopAndCollect(y); got != 7 {
+		t.Errorf("got %d, want 7", got)
+	}
+
+	if err := RestoreState(summing(1), []byte("not json")); err == nil {
+		t.Error("restoring garbage: got nil error")
+	}
+}
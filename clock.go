@@ -0,0 +1,45 @@
+package obs
+
+import "time"
+
+// A Clock provides the current time and tickers to time dependent features.
+// Replacing the real one allows them to be driven manually, for example in tests.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// A Ticker delivers periodic ticks, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is backed by the time package. Used wherever a Clock is left nil.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (x realTicker) C() <-chan time.Time {
+	return x.Ticker.C
+}
+
+// clockOr returns c, or the real clock if nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package obs
+
+import (
+	"context"
+	"time"
+)
+
+// samplerKey is the context key under which a Sampler is stored.
+// Keyed by type, so that Samplers of different instantiations don't shadow each other.
+type samplerKey[S any, T any] struct{}
+
+// WithSampler returns a copy of ctx carrying the Sampler, retrievable downstream by SamplerFrom.
+func WithSampler[S any, T any](ctx context.Context, x *Sampler[S, T]) context.Context {
+	return context.WithValue(ctx, samplerKey[S, T]{}, x)
+}
+
+// SamplerFrom returns the Sampler of the given type carried by ctx.
+// Returns false if there is none.
+func SamplerFrom[S any, T any](ctx context.Context) (*Sampler[S, T], bool) {
+	x, ok := ctx.Value(samplerKey[S, T]{}).(*Sampler[S, T])
+	return x, ok
+}
+
+// StartContext is like StartErr, but also stops the Sampler once ctx is done.
+// The remaining samples are still processed, as after Stop.
+func StartContext[S any, T any](ctx context.Context, x *Sampler[S, T]) error {
+	if err := StartErr(x); err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			Stop(x)
+		case <-x.done:
+		}
+	}()
+	return nil
+}
+
+// Drain stops the Sampler from accepting new samples, and waits up to timeout for the queued ones to be processed
+// and Final to return. Returns an error wrapping ErrTimeout if they don't finish in time, in which case processing
+// carries on in the background.
+func Drain[S any, T any](x *Sampler[S, T], timeout time.Duration) error {
+	return stopWithin(x, timeout)
+}
@@ -0,0 +1,81 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSamplerFrom(t *testing.T) {
+	ints := summing(1)
+	words := SamplerMake(1, func(s *string, v string) { *s += v })
+	ctx := WithSampler(WithSampler(context.Background(), ints), words)
+
+	if x, ok := SamplerFrom[int, int](ctx); !ok || x != ints {
+		t.Errorf("got %p, %v; want the int Sampler", x, ok)
+	}
+	if x, ok := SamplerFrom[string, string](ctx); !ok || x != words {
+		t.Errorf("got %p, %v; want the string Sampler", x, ok)
+	}
+	if _, ok := SamplerFrom[int, string](ctx); ok {
+		t.Error("found a Sampler of a type never stored")
+	}
+	if _, ok := SamplerFrom[int, int](context.Background()); ok {
+		t.Error("found a Sampler in an empty context")
+	}
+}
+
+func TestStartContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var final int
+	x := summing(4)
+	x.Final = func(s *int) { final = *s }
+	if err := StartContext(ctx, x); err != nil {
+		t.Fatal(err)
+	}
+	Sample(x, 1)
+	Sample(x, 2)
+	cancel()
+	select {
+	case <-x.Done():
+	case <-time.After(time.Second):
+		t.Fatal("not stopped by the context")
+	}
+	if final != 3 {
+		t.Errorf("got %d, want the remaining samples processed", final)
+	}
+
+	// stopped independently, without the context ending
+	y := summing(1)
+	if err := StartContext(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	StopAndWait(y)
+}
+
+func TestDrain(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	Sample(x, 1)
+	if err := Drain(x, time.Second); err != nil {
+		t.Errorf("prompt drain: %v", err)
+	}
+	if err := SampleErr(x, 1); err != ErrInactive {
+		t.Errorf("after Drain: got %v, want ErrInactive", err)
+	}
+
+	y, busy, release := blocked(4)
+	Start(y)
+	Sample(y, 0)
+	<-busy
+	Sample(y, 5)
+	if err := Drain(y, 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("stuck drain: got %v, want ErrTimeout", err)
+	}
+	close(release)
+	<-y.Done() // processing carries on in the background
+	if got := Snapshot(y); got != 5 {
+		t.Errorf("after unblocking: got %d, want 5", got)
+	}
+}
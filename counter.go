@@ -0,0 +1,47 @@
+package obs
+
+import "sync/atomic"
+
+// A Counter is an integer total that is only meant to increase.
+// The zero value is ready to use, and permits any addition.
+//
+// Its methods are concurrent safe.
+type Counter struct {
+	n      atomic.Int64
+	strict bool
+}
+
+// CounterMakeStrict returns a Counter that rejects negative additions, reporting them through the package Logger.
+// Useful for catching accounting bugs during development.
+func CounterMakeStrict() *Counter {
+	return &Counter{strict: true}
+}
+
+func (x *Counter) Add(delta int64) {
+	if x.strict && delta < 0 {
+		warn("obs: negative Counter addition", "delta", delta)
+		return
+	}
+	x.n.Add(delta)
+}
+
+func (x *Counter) Inc() {
+	x.n.Add(1)
+}
+
+func (x *Counter) AsFloat64() (float64, bool) {
+	return float64(x.n.Load()), true
+}
+
+func (x *Counter) Kind() string {
+	return "counter"
+}
+
+// Load returns the current total as an int64.
+func (x *Counter) Load() any {
+	return x.n.Load()
+}
+
+func (x *Counter) Value() int64 {
+	return x.n.Load()
+}
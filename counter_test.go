@@ -0,0 +1,27 @@
+package obs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterNegative(t *testing.T) {
+	log := captureLog(t)
+
+	var c Counter
+	c.Add(2)
+	c.Add(-1)
+	if c.Value() != 1 || log.Len() != 0 {
+		t.Errorf("default: got %d, logged %q; want 1 silently", c.Value(), log)
+	}
+
+	s := CounterMakeStrict()
+	s.Add(2)
+	s.Add(-1)
+	if s.Value() != 2 {
+		t.Errorf("strict: got %d, want the negative addition rejected", s.Value())
+	}
+	if !strings.Contains(log.String(), "negative Counter addition") {
+		t.Errorf("strict: logged %q", log)
+	}
+}
@@ -0,0 +1,132 @@
+package obs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DebugHandler returns a handler serving the live contents of a Map for inspection by operators, including members
+// of any Visibility, with their metadata, as a JSON array or an HTML table.
+//
+// The format is chosen by the "format" query parameter ("json" or "html") if present, otherwise by the Accept header,
+// defaulting to JSON. The "prefix" parameter restricts the listing to members whose label starts with it,
+// and "key" to the member whose key prints as it. Setting "refresh" renews members with Refresher Loaders before
+// they are loaded. Loader panics, and values that can't be encoded, are shown as errors in place of the value.
+func DebugHandler(m *Map) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = "json"
+			if strings.Contains(r.Header.Get("Accept"), "text/html") {
+				format = "html"
+			}
+		}
+		if format != "json" && format != "html" {
+			http.Error(w, "unknown format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		entries := debugEntries(m, query.Get("prefix"), query.Get("key"), query.Has("refresh"))
+
+		var err error
+		if format == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = debugTemplate.Execute(w, entries)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(entries)
+		}
+		if err != nil {
+			warn("obs: debug listing failed", "format", format, "err", err)
+		}
+	})
+}
+
+// A debugEntry is a member of a Map, as listed by DebugHandler.
+type debugEntry struct {
+	Key string `json:"key"`
+	valueJSON
+	Error string `json:"error,omitempty"`
+}
+
+// Text returns the entry's value as formatted by text encodings.
+func (x debugEntry) Text() string {
+	if x.Error != "" {
+		return x.Error
+	}
+	text, err := textOf(x.Value)
+	if err != nil {
+		return err.Error()
+	}
+	return text
+}
+
+// TagText returns the entry's tags in OpenMetrics label syntax.
+func (x debugEntry) TagText() string {
+	return openMetricsLabels(x.Tags)
+}
+
+// debugEntries loads the matching members of a Map, sorted by label, then by tags.
+// An empty prefix or key matches everything.
+func debugEntries(m *Map, prefix, key string, refresh bool) []debugEntry {
+	o := []debugEntry{}
+	for k, v := range m.all() {
+		keyText := fmt.Sprint(k)
+		if !strings.HasPrefix(v.Label, prefix) || key != "" && keyText != key {
+			continue
+		}
+
+		if r, ok := v.Loader.(Refresher); ok && refresh {
+			r.Refresh()
+		}
+
+		e := debugEntry{
+			Key: keyText,
+			valueJSON: valueJSON{
+				Label: v.Label,
+				Unit:  v.Unit,
+				Help:  v.Help,
+				Tags:  v.Tags,
+			},
+		}
+		if k, ok := v.Loader.(Kinded); ok {
+			e.Type = k.Kind()
+		}
+		loaded, err := tryLoad(v)
+		if err == nil {
+			// keep values that can't be encoded (e.g. NaNs) from failing the whole listing
+			_, err = json.Marshal(loaded)
+		}
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Value = loaded
+		}
+		o = append(o, e)
+	}
+
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Label != o[j].Label {
+			return o[i].Label < o[j].Label
+		}
+		return o[i].TagText() < o[j].TagText()
+	})
+	return o
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>obs</title></head>
+<body>
+<table>
+<tr><th>Label</th><th>Tags</th><th>Type</th><th>Value</th><th>Unit</th><th>Key</th><th>Help</th></tr>
+{{range .}}<tr><td>{{.Label}}</td><td>{{.TagText}}</td><td>{{.Type}}</td><td>{{.Text}}</td><td>{{.Unit}}</td><td>{{.Key}}</td><td>{{.Help}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,88 @@
+package obs
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	captureLog(t)
+
+	calls := 0
+	m := MapMake()
+	m.Set("a", Value{Label: "db.open", Loader: constant(3), Unit: "connections", Help: "open connections"})
+	m.Set("b", Value{Label: "db.cached", Loader: Cached(time.Hour, LoaderFunc[int](func() int { calls++; return calls }))})
+	m.Set(7, Value{Label: "internal", Loader: constant(math.NaN()), Visibility: Debug})
+	m.Set("c", Value{Label: "broken", Loader: LoaderFunc[int](func() int { panic("boom") })})
+	srv := httptest.NewServer(DebugHandler(m))
+	defer srv.Close()
+
+	get := func(query, accept string) (int, string, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+	list := func(query string) []map[string]any {
+		t.Helper()
+		_, _, body := get(query, "")
+		var o []map[string]any
+		if err := json.Unmarshal([]byte(body), &o); err != nil {
+			t.Fatalf("%s: %v in %s", query, err, body)
+		}
+		return o
+	}
+
+	all := list("")
+	var labels []string
+	for _, e := range all {
+		labels = append(labels, e["label"].(string))
+	}
+	if got := strings.Join(labels, " "); got != "broken db.cached db.open internal" {
+		t.Errorf("labels: got %s", got)
+	}
+	if e := all[0]; e["error"] == nil || e["value"] != nil {
+		t.Errorf("panicking Loader: got %v", e)
+	}
+	if e := all[3]; e["key"] != "7" || e["error"] == nil {
+		t.Errorf("NaN: got %v", e)
+	}
+
+	if got := list("?prefix=db."); len(got) != 2 {
+		t.Errorf("prefix: got %v", got)
+	}
+	got := list("?key=a")
+	if len(got) != 1 || got[0]["value"] != 3.0 || got[0]["unit"] != "connections" || got[0]["help"] != "open connections" {
+		t.Errorf("key: got %v", got)
+	}
+
+	if got := list("?key=b"); got[0]["value"] != 1.0 {
+		t.Errorf("cached: got %v", got)
+	}
+	if got := list("?key=b&refresh"); got[0]["value"] != 2.0 {
+		t.Errorf("refreshed: got %v", got)
+	}
+
+	status, contentType, body := get("?prefix=db.open", "text/html")
+	if status != http.StatusOK || contentType != "text/html; charset=utf-8" ||
+		!strings.Contains(body, "<td>db.open</td><td></td><td></td><td>3</td><td>connections</td><td>a</td><td>open connections</td>") {
+		t.Errorf("html: got %d %s %s", status, contentType, body)
+	}
+	if status, _, _ := get("?format=xml", ""); status != http.StatusBadRequest {
+		t.Errorf("unknown format: got %d", status)
+	}
+}
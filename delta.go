@@ -0,0 +1,100 @@
+package obs
+
+import (
+	"sync"
+	"time"
+)
+
+// A Change is the difference in a numeric member of a Map between two snapshots.
+type Change struct {
+	Key   any
+	Label string
+	Tags  map[string]string
+	Delta float64
+	Rate  float64 // Delta per second; 0 if no time elapsed
+	Reset bool    // the member is a counter that went down, and so is taken to have restarted from 0
+}
+
+// CounterDelta returns the increase of a counter from prev to cur.
+// A decrease is taken to be a reset, after which the counter restarted from 0, so the increase is cur itself.
+func CounterDelta(prev, cur float64) (delta float64, reset bool) {
+	if cur < prev {
+		return cur, true
+	}
+	return cur - prev, false
+}
+
+// Diff returns the Changes of the numeric members from prev to cur, two Snapshots of the same Map taken elapsed apart,
+// in the order of cur. Members are matched by key; those missing from either snapshot are left out.
+// Members of the "counter" Kind are handled as by CounterDelta, all others are plainly subtracted.
+func Diff(prev, cur []Entry, elapsed time.Duration) []Change {
+	before := make(map[any]float64, len(prev))
+	for _, e := range prev {
+		if f, ok := toFloat(e.Value); ok {
+			before[e.Key] = f
+		}
+	}
+
+	o := make([]Change, 0, len(cur))
+	for _, e := range cur {
+		f, ok := toFloat(e.Value)
+		if !ok {
+			continue
+		}
+		p, ok := before[e.Key]
+		if !ok {
+			continue
+		}
+
+		c := Change{
+			Key:   e.Key,
+			Label: e.Label,
+			Tags:  e.Tags,
+			Delta: f - p,
+		}
+		if e.Kind == "counter" {
+			c.Delta, c.Reset = CounterDelta(p, f)
+		}
+		if elapsed > 0 {
+			c.Rate = c.Delta / elapsed.Seconds()
+		}
+		o = append(o, c)
+	}
+	return o
+}
+
+// A DeltaTracker reports how the numeric members of a Map changed since it was last asked,
+// for "since the last scrape" views.
+//
+// Its methods are concurrent safe.
+type DeltaTracker struct {
+	Clock Clock // time source for rates; the real clock if nil
+
+	m        *Map
+	prev     []Entry
+	prevTime time.Time
+	mux      sync.Mutex
+}
+
+func DeltaTrackerMake(m *Map) *DeltaTracker {
+	return &DeltaTracker{
+		m: m,
+	}
+}
+
+// Next takes a Snapshot of the Map, and returns its Changes since the previous call, as by Diff.
+// The first call only records the baseline, and returns nil.
+func (x *DeltaTracker) Next() []Change {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	now := clockOr(x.Clock).Now()
+	cur := x.m.Snapshot()
+
+	var o []Change
+	if x.prev != nil {
+		o = Diff(x.prev, cur, now.Sub(x.prevTime))
+	}
+	x.prev, x.prevTime = cur, now
+	return o
+}
@@ -0,0 +1,57 @@
+package obs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestCounterDelta(t *testing.T) {
+	if d, reset := obs.CounterDelta(5, 8); d != 3 || reset {
+		t.Errorf("increase: got %v, %t", d, reset)
+	}
+	if d, reset := obs.CounterDelta(8, 2); d != 2 || !reset {
+		t.Errorf("reset: got %v, %t", d, reset)
+	}
+}
+
+func TestDeltaTracker(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	var requests obs.Counter
+	temperature := 20.0
+	m := obs.MapMake()
+	m.Set("r", obs.Value{Label: "requests", Loader: &requests})
+	m.Set("t", obs.Value{Label: "temperature", Loader: obs.LoaderFunc[float64](func() float64 { return temperature })})
+	m.Set("v", obs.Value{Label: "version", Loader: obs.LoaderFunc[string](func() string { return "v1" })})
+
+	x := obs.DeltaTrackerMake(m)
+	x.Clock = clock
+	if got := x.Next(); got != nil {
+		t.Errorf("baseline: got %v", got)
+	}
+
+	requests.Add(10)
+	temperature = 18
+	m.Set("new", obs.Value{Label: "new", Loader: obs.LoaderFunc[int](func() int { return 1 })})
+	clock.Advance(2 * time.Second)
+	want := []obs.Change{
+		{Key: "r", Label: "requests", Delta: 10, Rate: 5},
+		{Key: "t", Label: "temperature", Delta: -2, Rate: -1},
+	}
+	if got := x.Next(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// a restarted counter
+	var restarted obs.Counter
+	restarted.Add(4)
+	m.Set("r", obs.Value{Label: "requests", Loader: &restarted})
+	clock.Advance(time.Second)
+	got := x.Next()
+	if len(got) != 3 || !reflect.DeepEqual(got[1], obs.Change{Key: "r", Label: "requests", Delta: 4, Rate: 4, Reset: true}) {
+		t.Errorf("after a reset: got %+v", got)
+	}
+}
@@ -0,0 +1,159 @@
+package obs
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxPacket is the datagram size limit used by a DogStatsDSink if MaxPacket is not set,
+// chosen to fit in a typical Ethernet MTU.
+const DefaultMaxPacket = 1432
+
+var dogStatsDEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+// A DogStatsDSink sends metrics over UDP in the DogStatsD format (name:value|type|#tag:value,...),
+// packing as many lines into each datagram as fit within MaxPacket.
+//
+// Export sends the numeric members of a Map along with their Tags. Counters are sent as "c" increments since the
+// previous Export, Kinded "histogram" and "distribution" Loaders as "h" and "d", and everything else as gauges.
+// As a Sink, snapshots carry no metadata, so all numeric values are sent as gauges.
+//
+// Its methods are concurrent safe.
+type DogStatsDSink struct {
+	MaxPacket int // largest datagram to send; DefaultMaxPacket if 0
+
+	conn net.Conn
+	prev map[string]float64 // last exported totals of counters, by series; only those of the latest Export
+	mux  sync.Mutex
+}
+
+// DogStatsDSinkMake returns a DogStatsDSink sending to the given UDP address (e.g. "localhost:8125").
+// Close must be called when it is no longer needed.
+func DogStatsDSinkMake(addr string) (*DogStatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &DogStatsDSink{
+		conn: conn,
+		prev: make(map[string]float64),
+	}, nil
+}
+
+func (x *DogStatsDSink) Close() error {
+	return x.conn.Close()
+}
+
+// Export sends the numeric Public members of a Map. Non-numeric values are skipped.
+// Counters missing from an Export are forgotten, and sent as a full total if they reappear.
+func (x *DogStatsDSink) Export(m *Map) error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	prev := make(map[string]float64, len(x.prev))
+	var lines []string
+	for _, v := range visible(metrics(m), Public) {
+		f, ok := loadFloat(v)
+		if !ok {
+			continue
+		}
+
+		name := dogStatsDEscaper.Replace(v.Label)
+		tags := dogStatsDTags(v.Tags)
+		typ := "g"
+		if k, ok := v.Loader.(Kinded); ok {
+			switch k.Kind() {
+			case "counter":
+				typ = "c"
+				series := name + tags
+				total := f
+				if prev, ok := x.prev[series]; ok && prev <= total {
+					f -= prev
+				}
+				prev[series] = total
+			case "histogram":
+				typ = "h"
+			case "distribution":
+				typ = "d"
+			}
+		}
+		lines = append(lines, name+":"+formatFloat(f)+"|"+typ+tags)
+	}
+	x.prev = prev
+	return x.send(lines)
+}
+
+// Write sends a snapshot, as gauges.
+func (x *DogStatsDSink) Write(v map[string]any) error {
+	var lines []string
+	for _, v := range snapshotValues(v) {
+		if f, ok := loadFloat(v); ok {
+			lines = append(lines, dogStatsDEscaper.Replace(v.Label)+":"+formatFloat(f)+"|g"+dogStatsDTags(v.Tags))
+		}
+	}
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	return x.send(lines)
+}
+
+// send writes lines in as few datagrams as the size limit allows.
+// Lines that don't fit in a datagram by themselves are sent alone.
+func (x *DogStatsDSink) send(lines []string) error {
+	limit := x.MaxPacket
+	if limit <= 0 {
+		limit = DefaultMaxPacket
+	}
+
+	var b strings.Builder
+	flush := func() error {
+		if b.Len() == 0 {
+			return nil
+		}
+		_, err := x.conn.Write([]byte(b.String()))
+		b.Reset()
+		return err
+	}
+
+	for _, line := range lines {
+		if b.Len() > 0 && b.Len()+1+len(line) > limit {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+	return flush()
+}
+
+// dogStatsDTags formats tags as a DogStatsD tag section, sorted by name.
+// Returns an empty string if there are no tags.
+func dogStatsDTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("|#")
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(dogStatsDEscaper.Replace(k) + ":" + dogStatsDTagEscaper.Replace(tags[k]))
+	}
+	return b.String()
+}
+
+// tag values may contain colons
+var dogStatsDTagEscaper = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
@@ -0,0 +1,101 @@
+package obs
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dogStatsDListen(t *testing.T) (*net.UDPConn, *DogStatsDSink) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	sink, err := DogStatsDSinkMake(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return conn, sink
+}
+
+func dogStatsDRead(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestDogStatsDCounterDeltas(t *testing.T) {
+	conn, sink := dogStatsDListen(t)
+
+	var c Counter
+	var g Gauge
+	g.Set(1)
+	var m Map
+	m.Set("req", Value{Label: "requests", Loader: &c, Tags: map[string]string{"code": "200"}})
+	m.Set("up", Value{Label: "up", Loader: &g})
+
+	c.Add(5)
+	if err := sink.Export(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dogStatsDRead(t, conn), "requests:5|c|#code:200\nup:1|g"; got != want {
+		t.Errorf("first export: got %q, want %q", got, want)
+	}
+
+	c.Add(3)
+	sink.Export(&m)
+	if got := dogStatsDRead(t, conn); !strings.Contains(got, "requests:3|c|#code:200") {
+		t.Errorf("second export: %q", got)
+	}
+}
+
+func TestDogStatsDPrunesRemovedCounters(t *testing.T) {
+	conn, sink := dogStatsDListen(t)
+
+	var c Counter
+	var g Gauge
+	g.Set(1)
+	var m Map
+	m.Set("req", Value{Label: "requests", Loader: &c})
+	m.Set("up", Value{Label: "up", Loader: &g})
+
+	c.Add(5)
+	sink.Export(&m)
+	dogStatsDRead(t, conn)
+
+	m.Delete("req")
+	sink.Export(&m)
+	dogStatsDRead(t, conn)
+	if len(sink.prev) != 0 {
+		t.Errorf("kept totals of removed counters: %v", sink.prev)
+	}
+
+	m.Set("req", Value{Label: "requests", Loader: &c})
+	sink.Export(&m)
+	if got := dogStatsDRead(t, conn); !strings.Contains(got, "requests:5|c") {
+		t.Errorf("reappearing counter: %q", got)
+	}
+}
+
+func TestDogStatsDWriteTags(t *testing.T) {
+	conn, sink := dogStatsDListen(t)
+
+	var m Map
+	m.Set("ok", Value{Label: "requests", Loader: constant(3), Tags: map[string]string{"code": "200"}})
+	m.Set("failed", Value{Label: "requests", Loader: constant(1), Tags: map[string]string{"code": "500"}})
+	if err := sink.Write(m.labeled()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dogStatsDRead(t, conn), "requests:3|g|#code:200\nrequests:1|g|#code:500"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
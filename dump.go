@@ -0,0 +1,95 @@
+package obs
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+)
+
+var (
+	registered    = make(map[string]*Map)
+	registeredMux sync.Mutex
+)
+
+// Register adds a Map to the package level set dumped by Dump, under the given name.
+// Replaces any Map previously registered under the same name.
+func Register(name string, m *Map) {
+	registeredMux.Lock()
+	registered[name] = m
+	registeredMux.Unlock()
+}
+
+func Unregister(name string) {
+	registeredMux.Lock()
+	delete(registered, name)
+	registeredMux.Unlock()
+}
+
+// Dump writes all registered Maps to w as a JSON object, keyed by name, followed by a newline.
+// Each Map is encoded as by StreamJSON.
+func Dump(w io.Writer) error {
+	registeredMux.Lock()
+	names := make([]string, 0, len(registered))
+	maps := make(map[string]*Map, len(registered))
+	for k, v := range registered {
+		names = append(names, k)
+		maps[k] = v
+	}
+	registeredMux.Unlock()
+	sort.Strings(names)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i, name := range names {
+		key, _ := json.Marshal(name)
+		if i > 0 {
+			key = append([]byte{','}, key...)
+		}
+		key = append(key, ':')
+		if _, err := w.Write(key); err != nil {
+			return err
+		}
+		if err := StreamJSON(w, maps[name]); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// InstallSignalDump makes every arrival of the given signal Dump the registered Maps to w.
+// Dump errors are reported through the package Logger.
+// The returned function uninstalls the handler.
+func InstallSignalDump(sig os.Signal, w io.Writer) (uninstall func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	signal.Notify(sigs, sig)
+
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+				if err := Dump(w); err != nil {
+					warn("obs: signal dump failed", "err", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			<-exited
+		})
+	}
+}
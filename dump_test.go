@@ -0,0 +1,40 @@
+package obs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	Register("first", MapOf(Value{Label: "a", Loader: constant(1)}))
+	Register("second", MapOf(Value{Label: "b", Loader: constant("x")}))
+	Register("second", MapOf(Value{Label: "b", Loader: constant("y")}))
+	defer Unregister("first")
+	defer Unregister("second")
+
+	var b bytes.Buffer
+	if err := Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `{"first":{"a":1},"second":{"b":"y"}}`+"\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	Unregister("first")
+	b.Reset()
+	Dump(&b)
+	if got, want := b.String(), `{"second":{"b":"y"}}`+"\n"; got != want {
+		t.Errorf("after Unregister: got %s, want %s", got, want)
+	}
+}
+
+func TestInstallSignalDump(t *testing.T) {
+	var b bytes.Buffer
+	uninstall := InstallSignalDump(os.Interrupt, &b)
+	uninstall()
+	uninstall()
+	if b.Len() != 0 {
+		t.Errorf("dumped %q without a signal", b.String())
+	}
+}
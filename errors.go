@@ -0,0 +1,40 @@
+package obs
+
+import "fmt"
+
+// ErrorCounts holds numbers of errors by category.
+type ErrorCounts map[string]uint64
+
+func (x *ErrorCounts) Clone() ErrorCounts {
+	o := make(ErrorCounts, len(*x))
+	for k, v := range *x {
+		o[k] = v
+	}
+	return o
+}
+
+func (x *ErrorCounts) Reset() {
+	*x = make(ErrorCounts)
+}
+
+// ErrorSampler returns a Sampler that counts errors by the category categorize assigns them, such as their type or
+// the sentinel they match with errors.Is. Nil errors are counted under "none", without calling categorize.
+// If categorize is nil, errors are categorized by their dynamic type.
+//
+// Like any Sampler, it is a Loader of its state, in this case an ErrorCounts.
+func ErrorSampler(queueSize int, categorize func(error) string) *Sampler[ErrorCounts, error] {
+	if categorize == nil {
+		categorize = func(err error) string {
+			return fmt.Sprintf("%T", err)
+		}
+	}
+
+	state := make(ErrorCounts)
+	return SamplerMakeState(queueSize, &state, func(s *ErrorCounts, err error) {
+		category := "none"
+		if err != nil {
+			category = categorize(err)
+		}
+		(*s)[category]++
+	})
+}
@@ -0,0 +1,107 @@
+package obs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorSampler(t *testing.T) {
+	x := ErrorSampler(16, func(err error) string {
+		var perr *fs.PathError
+		switch {
+		case errors.Is(err, io.EOF):
+			return "eof"
+		case errors.As(err, &perr):
+			return "path"
+		}
+		return "other"
+	})
+	Start(x)
+	for _, err := range []error{
+		nil,
+		io.EOF,
+		fmt.Errorf("reading header: %w", io.EOF),
+		fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", io.EOF)),
+		&fs.PathError{Op: "open", Path: "x", Err: os.ErrNotExist},
+		fmt.Errorf("config: %w", &fs.PathError{Op: "stat", Path: "y", Err: os.ErrPermission}),
+		errors.New("boom"),
+		nil,
+	} {
+		Sample(x, err)
+	}
+	got := StopAndCollect(x)
+	want := ErrorCounts{"none": 2, "eof": 3, "path": 2, "other": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestErrorSamplerByType(t *testing.T) {
+	x := ErrorSampler(4, nil)
+	Start(x)
+	Sample(x, io.EOF)
+	Sample[ErrorCounts, error](x, &fs.PathError{})
+	Sample(x, nil)
+	got := StopAndCollect(x)
+	want := ErrorCounts{"*errors.errorString": 1, "*fs.PathError": 1, "none": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the state is a Loader of the counts
+	y := ErrorSampler(4, nil)
+	Start(y)
+	Sample(y, io.EOF)
+	Flush(y)
+	if got, ok := y.Load().(ErrorCounts); !ok || got["*errors.errorString"] != 1 {
+		t.Errorf("Load: got %v", y.Load())
+	}
+	Stop(y)
+}
+
+func TestSamplerMakeErr(t *testing.T) {
+	errOdd := errors.New("odd")
+	x := SamplerMakeErr(8, func(s *int, v int) error {
+		if v%2 != 0 {
+			return errOdd
+		}
+		*s += v
+		return nil
+	})
+	var failed []int
+	x.OnError = func(v int, err error) bool {
+		if err != errOdd {
+			t.Errorf("got error %v", err)
+		}
+		failed = append(failed, v)
+		return v > 10
+	}
+	Start(x)
+	for _, v := range []int{2, 3, 4, 5} {
+		Sample(x, v)
+	}
+	Flush(x)
+	if got := Snapshot(x); got != 6 {
+		t.Errorf("got %d, want 6", got)
+	}
+	if n := Failed(x); n != 2 {
+		t.Errorf("failed: got %d, want 2", n)
+	}
+
+	// OnError may stop the Sampler
+	Sample(x, 11)
+	select {
+	case <-x.Done():
+	case <-time.After(time.Second):
+		t.Fatal("not stopped by OnError")
+	}
+	if want := []int{3, 5, 11}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("OnError got %v, want %v", failed, want)
+	}
+}
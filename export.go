@@ -0,0 +1,163 @@
+package obs
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Numeric Loader can provide its value as a float64 directly, sparing exporters a Load and type inspection.
+// Returns false if the current value is not numeric.
+type Numeric interface {
+	AsFloat64() (float64, bool)
+}
+
+// metrics returns all members of a Map, sorted by label, then by tags, so that exports are deterministic.
+func metrics(m *Map) []Value {
+	values := m.all()
+	o := make([]Value, 0, len(values))
+	for _, v := range values {
+		o = append(o, v)
+	}
+
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Label != o[j].Label {
+			return o[i].Label < o[j].Label
+		}
+		return openMetricsLabels(o[i].Tags) < openMetricsLabels(o[j].Tags)
+	})
+	return o
+}
+
+// visible returns the values exposed at the given level.
+// The input is left untouched, as it may be a shared snapshot.
+func visible(values []Value, level Visibility) []Value {
+	o := make([]Value, 0, len(values))
+	for _, v := range values {
+		if v.Visibility <= level {
+			o = append(o, v)
+		}
+	}
+	return o
+}
+
+// WriteFormat writes the members of a Map that are visible at the given level, in the named format:
+// "json", "prometheus" or "openmetrics", as by StreamJSON, WritePrometheus and WriteOpenMetrics respectively.
+// Returns ErrFormat if the format is unknown.
+func WriteFormat(w io.Writer, m *Map, format string, level Visibility) error {
+	f, ok := exportFormats[format]
+	if !ok {
+		return ErrFormat
+	}
+	return f.write(w, visible(metrics(m), level))
+}
+
+// LoadFloat obtains a numeric value from a Value the way the built-in exporters do, for use by other exporters.
+// Prefers the Numeric interface, and otherwise converts the loaded value if it is a number or boolean.
+// Returns false if the value is not numeric, or if its Loader panicked.
+func LoadFloat(v Value) (float64, bool) {
+	return loadFloat(v)
+}
+
+// loadFloat obtains a numeric value for export, preferring the Numeric interface.
+// Returns false if the value is not numeric, or if obtaining it panicked.
+func loadFloat(v Value) (o float64, ok bool) {
+	if n, isNumeric := v.Loader.(Numeric); isNumeric {
+		defer func() {
+			if r := recover(); r != nil {
+				warn("obs: Loader panicked", "label", v.Label, "panic", r)
+				o, ok = 0, false
+			}
+		}()
+		return n.AsFloat64()
+	}
+
+	loaded, ok := safeLoad(v)
+	if !ok {
+		return 0, false
+	}
+	return toFloat(loaded)
+}
+
+// sanitizeName converts a label into a valid Prometheus/OpenMetrics metric name,
+// replacing invalid characters with underscores.
+func sanitizeName(label string) string {
+	var b strings.Builder
+	b.Grow(len(label) + 1)
+	for i, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// toFloat converts numeric (and boolean) loaded values to float64.
+// Returns false for anything else.
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uintptr:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// isCounter reports whether a Value should be exported as a counter.
+func isCounter(v Value) bool {
+	k, ok := v.Loader.(Kinded)
+	return ok && k.Kind() == "counter"
+}
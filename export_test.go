@@ -0,0 +1,110 @@
+package obs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// boxed is a non Numeric Loader of an int, exported through reflection based conversion.
+type boxed int
+
+func (x boxed) Load() any {
+	return int(x)
+}
+
+func TestLoadFloatSkipsNonNumeric(t *testing.T) {
+	m := MapMake()
+	m.Set("n", Value{Label: "n", Loader: &Counter{}})
+	m.Set("s", Value{Label: "s", Loader: LoaderFunc[string](func() string { return "text" })})
+
+	var b bytes.Buffer
+	if err := WritePrometheus(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.Contains(out, "n_total 0") || strings.Contains(out, "s ") {
+		t.Errorf("unexpected export:\n%s", out)
+	}
+
+	if _, ok := LoadFloat(Value{Label: "s", Loader: LoaderFunc[string](func() string { return "text" })}); ok {
+		t.Error("non-numeric value loaded as numeric")
+	}
+}
+
+func TestWriteFormatVisibility(t *testing.T) {
+	m := MapOf(
+		Value{Label: "requests", Loader: constant(3)},
+		Value{Label: "queue_debug", Loader: constant(7), Visibility: Debug},
+	)
+	for _, c := range []struct {
+		format string
+		level  Visibility
+		want   string
+	}{
+		{"prometheus", Public, "# TYPE requests gauge\nrequests 3\n"},
+		{"json", Public, `{"requests":3}`},
+		{"json", Debug, `{"queue_debug":7,"requests":3}`},
+		{"prometheus", Debug, "# TYPE queue_debug gauge\nqueue_debug 7\n# TYPE requests gauge\nrequests 3\n"},
+	} {
+		var buf bytes.Buffer
+		if err := WriteFormat(&buf, m, c.format, c.level); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != c.want {
+			t.Errorf("%s at level %d: got %q, want %q", c.format, c.level, got, c.want)
+		}
+	}
+
+	// the unfiltered exports
+	var buf bytes.Buffer
+	if err := StreamJSON(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `{"queue_debug":7,"requests":3}`; got != want {
+		t.Errorf("StreamJSON: got %q, want %q", got, want)
+	}
+}
+
+func benchExport(b *testing.B, loader func(int) Loader) {
+	m := MapMake()
+	for i := 0; i < 100; i++ {
+		m.Set(i, Value{Label: fmt.Sprint("v", i), Loader: loader(i)})
+	}
+	values := metrics(m)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writePrometheus(io.Discard, values)
+	}
+}
+
+func BenchmarkExportNumeric(b *testing.B) {
+	benchExport(b, func(int) Loader {
+		return &Counter{}
+	})
+}
+
+func BenchmarkExportLoaded(b *testing.B) {
+	benchExport(b, func(i int) Loader {
+		return boxed(i)
+	})
+}
+
+func BenchmarkLoadFloatNumeric(b *testing.B) {
+	v := Value{Label: "v", Loader: &Gauge{}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		loadFloat(v)
+	}
+}
+
+func BenchmarkLoadFloatLoaded(b *testing.B) {
+	v := Value{Label: "v", Loader: boxed(1000)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		loadFloat(v)
+	}
+}
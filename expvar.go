@@ -0,0 +1,30 @@
+package obs
+
+import (
+	"encoding/json"
+	"expvar"
+)
+
+// PublishSampler exposes the Sampler's state through expvar, under the given name.
+// The published value is the JSON encoding of the state, as by MarshalState, taken whenever it is read.
+// Like expvar.Publish, panics if the name is already in use.
+func PublishSampler[S any, T any](name string, x *Sampler[S, T]) {
+	expvar.Publish(name, expvar.Func(func() any {
+		data, err := MarshalState(x)
+		if err != nil {
+			return nil
+		}
+		return json.RawMessage(data)
+	}))
+}
+
+// PublishMap exposes the loaded members of a Map through expvar, as a single JSON object keyed by label (see SnapshotJSON),
+// under the given name. The object is rebuilt whenever it is read, so it follows the Map as members are Set and Deleted,
+// which individually published expvars could not, as expvar has no way of removing them.
+// Members of any Visibility are included. Like expvar.Publish, panics if the name is already in use.
+func PublishMap(name string, m *Map) {
+	expvar.Publish(name, expvar.Func(func() any {
+		data, _ := m.SnapshotJSON()
+		return json.RawMessage(data)
+	}))
+}
@@ -0,0 +1,48 @@
+package obs
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// published counts the expvars published by tests, keeping their names unique when tests are run repeatedly,
+// as expvar can't remove them.
+var published atomic.Int32
+
+func expvarName(base string) string {
+	return fmt.Sprintf("%s_%d", base, published.Add(1))
+}
+
+func TestPublishSampler(t *testing.T) {
+	x := summing(4)
+	name := expvarName("obs_test_sum")
+	PublishSampler(name, x)
+	Start(x)
+	Sample(x, 1)
+	Sample(x, 2)
+	StopAndWait(x)
+
+	if got := expvar.Get(name).String(); got != "3" {
+		t.Errorf("got %s, want 3", got)
+	}
+}
+
+func TestPublishMap(t *testing.T) {
+	m := MapMake()
+	m.Set("a", Value{Label: "a", Loader: constant(1)})
+	name := expvarName("obs_test_map")
+	PublishMap(name, m)
+	v := expvar.Get(name)
+	if got := v.String(); got != `{"a":1}` {
+		t.Errorf("got %s", got)
+	}
+
+	// follows the Map
+	m.Set("b", Value{Label: "b", Loader: constant("x"), Visibility: Debug})
+	m.Delete("a")
+	if got := v.String(); got != `{"b":"x"}` {
+		t.Errorf("after Set and Delete: got %s", got)
+	}
+}
@@ -0,0 +1,124 @@
+package obs
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// A FileSink appends JSON lines to a file through a buffer, which is flushed periodically and when the FileSink is stopped.
+// Besides being a Sink, it can record arbitrary values, such as Sampler states from OnWindow or Final:
+//
+//	x.Final = func(s *S) { sink.Encode(*s) }
+//
+// Its methods are concurrent safe.
+type FileSink struct {
+	Clock Clock       // time source for the flush ticker; the real clock if nil
+	Error func(error) // called with write and flush errors, if non-nil
+
+	file     *os.File
+	buf      *bufio.Writer
+	enc      *json.Encoder
+	interval time.Duration
+
+	started bool
+	stopped bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mux     sync.Mutex
+}
+
+// FileSinkMake opens (or creates) the file at path for appending, flushing buffered writes to it every interval.
+// The flushing goroutine is launched by the first write, so any Clock must be assigned before then.
+// Stop must be called when the FileSink is no longer needed.
+func FileSinkMake(path string, interval time.Duration) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &FileSink{
+		file:     f,
+		buf:      bufio.NewWriter(f),
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	x.enc = json.NewEncoder(x.buf)
+	return x, nil
+}
+
+// Encode buffers v as a JSON line.
+// Returns os.ErrClosed if the FileSink has been stopped.
+func (x *FileSink) Encode(v any) error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if x.stopped {
+		return os.ErrClosed
+	}
+	if !x.started {
+		x.started = true
+		x.wg.Add(1)
+		go x.loop(clockOr(x.Clock).NewTicker(x.interval))
+	}
+	return x.report(x.enc.Encode(v))
+}
+
+// Flush writes out buffered data.
+func (x *FileSink) Flush() error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	return x.report(x.buf.Flush())
+}
+
+// Stop terminates the flushing goroutine, flushes remaining buffered data and closes the file.
+// Subsequent calls are NoOps.
+func (x *FileSink) Stop() error {
+	x.mux.Lock()
+	if x.stopped {
+		x.mux.Unlock()
+		return nil
+	}
+	x.stopped = true
+	close(x.done)
+	x.mux.Unlock()
+
+	x.wg.Wait()
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	err := x.buf.Flush()
+	if cerr := x.file.Close(); err == nil {
+		err = cerr
+	}
+	return x.report(err)
+}
+
+// Write buffers a snapshot as a JSON line, making the FileSink a Sink.
+func (x *FileSink) Write(v map[string]any) error {
+	return x.Encode(v)
+}
+
+func (x *FileSink) loop(ticker Ticker) {
+	defer x.wg.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-x.done:
+			return
+		case <-ticker.C():
+			x.Flush()
+		}
+	}
+}
+
+// report passes a non-nil error to the Error callback, and returns it.
+func (x *FileSink) report(err error) error {
+	if err != nil && x.Error != nil {
+		x.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package obs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.jsonl")
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x, err := obs.FileSinkMake(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Clock = clock
+
+	read := func() string {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if err := x.Write(map[string]any{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "" {
+		t.Errorf("before flushing: got %q, want nothing", got)
+	}
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read(), "{\"n\":1}\n"; got != want {
+		t.Errorf("after Flush: got %q, want %q", got, want)
+	}
+
+	// periodic flush
+	x.Encode(2)
+	clock.Advance(time.Second)
+	want := "{\"n\":1}\n2\n"
+	for deadline := time.Now().Add(time.Second); read() != want && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := read(); got != want {
+		t.Errorf("after a tick: got %q, want %q", got, want)
+	}
+
+	// Stop flushes
+	x.Encode("three")
+	if err := x.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read(), want+"\"three\"\n"; got != want {
+		t.Errorf("after Stop: got %q, want %q", got, want)
+	}
+	if err := x.Encode(4); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("after Stop: got %v, want os.ErrClosed", err)
+	}
+	if err := x.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
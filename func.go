@@ -0,0 +1,34 @@
+package obs
+
+// Field returns a sample function that extracts a field from each sample, and aggregates it using agg.
+// Combined with Join, it allows multi-field samples to be aggregated one field at a time:
+//
+//	type Request struct {
+//		Latency time.Duration
+//		Size    int
+//	}
+//
+//	type Stats struct {
+//		Latency time.Duration
+//		Bytes   int
+//	}
+//
+//	fn := Join(
+//		Field(func(r Request) time.Duration { return r.Latency }, func(s *Stats, v time.Duration) { s.Latency += v }),
+//		Field(func(r Request) int { return r.Size }, func(s *Stats, v int) { s.Bytes += v }),
+//	)
+//	x := SamplerMake(1024, fn)
+func Field[S any, T any, F any](get func(T) F, agg func(*S, F)) func(*S, T) {
+	return func(s *S, v T) {
+		agg(s, get(v))
+	}
+}
+
+// Join returns a sample function that calls each of the given functions in order.
+func Join[S any, T any](fns ...func(*S, T)) func(*S, T) {
+	return func(s *S, v T) {
+		for _, fn := range fns {
+			fn(s, v)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package obs
+
+import (
+	"testing"
+)
+
+func TestFieldJoin(t *testing.T) {
+	type request struct {
+		Path string
+		Size int
+	}
+	type stats struct {
+		Paths map[string]int
+		Bytes int
+	}
+
+	fn := Join(
+		Field(func(r request) string { return r.Path }, func(s *stats, v string) {
+			if s.Paths == nil {
+				s.Paths = make(map[string]int)
+			}
+			s.Paths[v]++
+		}),
+		Field(func(r request) int { return r.Size }, func(s *stats, v int) { s.Bytes += v }),
+	)
+	x := SamplerMake(4, fn)
+	Start(x)
+	Sample(x, request{"/a", 10})
+	Sample(x, request{"/b", 20})
+	Sample(x, request{"/a", 30})
+
+	got := StopAndCollect(x)
+	if got.Paths["/a"] != 2 || got.Paths["/b"] != 1 || got.Bytes != 60 {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,44 @@
+package obs
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// A Gauge is a float64 that may go up and down.
+// The zero value is ready to use, and holds 0.
+//
+// Its methods are concurrent safe.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (x *Gauge) Add(delta float64) {
+	for {
+		old := x.bits.Load()
+		if x.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (x *Gauge) AsFloat64() (float64, bool) {
+	return x.Value(), true
+}
+
+func (x *Gauge) Kind() string {
+	return "gauge"
+}
+
+// Load returns the current value as a float64.
+func (x *Gauge) Load() any {
+	return x.Value()
+}
+
+func (x *Gauge) Set(v float64) {
+	x.bits.Store(math.Float64bits(v))
+}
+
+func (x *Gauge) Value() float64 {
+	return math.Float64frombits(x.bits.Load())
+}
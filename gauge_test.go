@@ -0,0 +1,29 @@
+package obs
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGauge(t *testing.T) {
+	var x Gauge
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				x.Add(0.5)
+				x.Add(-0.25)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := x.Value(); got != 200 {
+		t.Errorf("got %v, want 200", got)
+	}
+	x.Set(-3)
+	if f, ok := x.AsFloat64(); !ok || f != -3 || x.Load() != -3.0 {
+		t.Errorf("after Set: got %v, %t", f, ok)
+	}
+}
@@ -0,0 +1,213 @@
+package obs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// An HDRHistogram records integer values (such as time.Durations) with a bounded relative error over a wide range,
+// in the manner of HdrHistogram: exact powers of two split into linear sub-buckets, as many as needed for the
+// requested number of significant decimal digits. Quantiles up to p99.9 and beyond are therefore as precise at one
+// microsecond as they are at ten seconds, unlike with fixed buckets.
+// Add is a sample function, for use with SamplerMakeState.
+//
+// Histograms of the same layout can be merged, for aggregating across Samplers.
+type HDRHistogram[T ~int64] struct {
+	Lowest  T   // smallest discernible value, at least 1
+	Highest T   // largest recordable value; larger values are recorded as Highest
+	Digits  int // significant decimal digits maintained, from 1 to 5
+
+	Counts []uint64
+	Count  uint64
+	Min    T // meaningless while Count is 0
+	Max    T // meaningless while Count is 0
+
+	// layout, derived from the exported configuration, so that decoded histograms work as well
+	unitMagnitude  int
+	halfMagnitude  int // of the sub-bucket half count
+	subBucketCount int64
+	subBucketMask  int64
+}
+
+// HDRHistogramMake returns an empty histogram of values from lowest to highest, with the given number of significant
+// decimal digits. Panics if lowest < 1, highest < 2*lowest or digits is not between 1 and 5.
+//
+// Memory use grows with the range and, exponentially, with the digits: a range of a microsecond to an hour at 3 digits,
+// in nanoseconds, takes about 24K counts.
+func HDRHistogramMake[T ~int64](lowest, highest T, digits int) *HDRHistogram[T] {
+	if lowest < 1 || highest < 2*lowest || digits < 1 || digits > 5 {
+		panic(fmt.Sprintf("obs: invalid HDRHistogram layout: %d to %d with %d digits", lowest, highest, digits))
+	}
+
+	o := &HDRHistogram[T]{
+		Lowest:  lowest,
+		Highest: highest,
+		Digits:  digits,
+	}
+	o.Reset()
+	return o
+}
+
+// HDRSampler returns a Sampler aggregating its samples into an HDRHistogram, as made by HDRHistogramMake.
+//
+// Like any Sampler, it is a Loader of its state, in this case an HDRHistogram.
+func HDRSampler[T ~int64](queueSize int, lowest, highest T, digits int) *Sampler[HDRHistogram[T], T] {
+	return SamplerMakeState(queueSize, HDRHistogramMake(lowest, highest, digits), (*HDRHistogram[T]).Add)
+}
+
+// Add records a value. Negative values are recorded as 0, and values above Highest as Highest.
+func (x *HDRHistogram[T]) Add(v T) {
+	x.init()
+	v = min(max(v, 0), x.Highest)
+
+	x.Counts[x.index(int64(v))]++
+	if x.Count == 0 || v < x.Min {
+		x.Min = v
+	}
+	if x.Count == 0 || v > x.Max {
+		x.Max = v
+	}
+	x.Count++
+}
+
+func (x *HDRHistogram[T]) Clone() HDRHistogram[T] {
+	o := *x
+	o.Counts = append([]uint64(nil), x.Counts...)
+	return o
+}
+
+// Mean returns the average of the recorded values, within the histogram's precision.
+// Returns 0 if there are none.
+func (x *HDRHistogram[T]) Mean() float64 {
+	if x.Count == 0 {
+		return 0
+	}
+
+	x.init()
+	var sum float64
+	for i, n := range x.Counts {
+		if n > 0 {
+			sum += float64(n) * x.median(i)
+		}
+	}
+	return sum / float64(x.Count)
+}
+
+// Merge adds the recorded values of another histogram of the same layout.
+// Returns an error, leaving x unchanged, if the layouts differ.
+func (x *HDRHistogram[T]) Merge(other *HDRHistogram[T]) error {
+	if x.Lowest != other.Lowest || x.Highest != other.Highest || x.Digits != other.Digits ||
+		len(x.Counts) != len(other.Counts) {
+		return errors.New("histogram layouts differ")
+	}
+	if other.Count == 0 {
+		return nil
+	}
+
+	for i, n := range other.Counts {
+		x.Counts[i] += n
+	}
+	if x.Count == 0 || other.Min < x.Min {
+		x.Min = other.Min
+	}
+	if x.Count == 0 || other.Max > x.Max {
+		x.Max = other.Max
+	}
+	x.Count += other.Count
+	return nil
+}
+
+// Quantile returns the value below which the fraction q of recorded values fall, such as 0.999 for p99.9,
+// as the highest value equivalent to it within the histogram's precision.
+// Returns 0 if there are no values.
+func (x *HDRHistogram[T]) Quantile(q float64) T {
+	if x.Count == 0 {
+		return 0
+	}
+
+	x.init()
+	rank := uint64(math.Ceil(min(max(q, 0), 1) * float64(x.Count)))
+	rank = max(rank, 1)
+
+	var seen uint64
+	for i, n := range x.Counts {
+		seen += n
+		if seen >= rank {
+			return min(T(x.highestEquivalent(i)), x.Max)
+		}
+	}
+	return x.Max
+}
+
+// Reset clears the recorded values, keeping the layout.
+func (x *HDRHistogram[T]) Reset() {
+	x.subBucketCount = 0
+	x.init()
+	*x = HDRHistogram[T]{
+		Lowest:         x.Lowest,
+		Highest:        x.Highest,
+		Digits:         x.Digits,
+		Counts:         make([]uint64, x.countsLen()),
+		unitMagnitude:  x.unitMagnitude,
+		halfMagnitude:  x.halfMagnitude,
+		subBucketCount: x.subBucketCount,
+		subBucketMask:  x.subBucketMask,
+	}
+}
+
+// init derives the layout from the configuration, if it hasn't been already.
+func (x *HDRHistogram[T]) init() {
+	if x.subBucketCount != 0 {
+		return
+	}
+
+	// sub-buckets must resolve 1 part in 2*10^digits, so that values are within 1 part in 10^digits of their bucket
+	resolution := 2 * int64(math.Pow10(x.Digits))
+	countMagnitude := bits.Len64(uint64(resolution - 1))
+	x.halfMagnitude = max(countMagnitude, 1) - 1
+	x.unitMagnitude = bits.Len64(uint64(x.Lowest)) - 1
+	x.subBucketCount = 1 << (x.halfMagnitude + 1)
+	x.subBucketMask = (x.subBucketCount - 1) << x.unitMagnitude
+}
+
+// countsLen returns the number of counts needed to cover the range up to Highest.
+func (x *HDRHistogram[T]) countsLen() int {
+	buckets := 1
+	for limit := x.subBucketCount << x.unitMagnitude; limit <= int64(x.Highest); limit <<= 1 {
+		buckets++
+		if limit > math.MaxInt64/2 {
+			break
+		}
+	}
+	return (buckets + 1) << x.halfMagnitude
+}
+
+// index returns the position of a value's count.
+func (x *HDRHistogram[T]) index(v int64) int {
+	bucket := 64 - x.unitMagnitude - x.halfMagnitude - 1 - bits.LeadingZeros64(uint64(v|x.subBucketMask))
+	sub := v >> (bucket + x.unitMagnitude)
+	return (bucket+1)<<x.halfMagnitude + int(sub) - 1<<x.halfMagnitude
+}
+
+// lowestEquivalent returns the lowest value counted at the given position, along with the width of its range.
+func (x *HDRHistogram[T]) lowestEquivalent(i int) (int64, int64) {
+	bucket := i>>x.halfMagnitude - 1
+	sub := int64(i&(1<<x.halfMagnitude-1)) + 1<<x.halfMagnitude
+	if bucket < 0 {
+		sub -= 1 << x.halfMagnitude
+		bucket = 0
+	}
+	return sub << (bucket + x.unitMagnitude), 1 << (bucket + x.unitMagnitude)
+}
+
+func (x *HDRHistogram[T]) highestEquivalent(i int) int64 {
+	low, width := x.lowestEquivalent(i)
+	return low + width - 1
+}
+
+func (x *HDRHistogram[T]) median(i int) float64 {
+	low, width := x.lowestEquivalent(i)
+	return float64(low) + float64(width-1)/2
+}
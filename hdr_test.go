@@ -0,0 +1,103 @@
+package obs
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHDRHistogram(t *testing.T) {
+	x := HDRHistogramMake[time.Duration](1, time.Hour, 3)
+	for i := 1; i <= 10000; i++ {
+		x.Add(time.Duration(i) * time.Microsecond)
+	}
+	if x.Count != 10000 || x.Min != time.Microsecond || x.Max != 10*time.Millisecond {
+		t.Errorf("got count %d, min %v, max %v", x.Count, x.Min, x.Max)
+	}
+	for _, q := range []float64{0.5, 0.99, 0.999} {
+		want := q * float64(10*time.Millisecond)
+		if got := float64(x.Quantile(q)); math.Abs(got-want)/want > 0.001 {
+			t.Errorf("quantile %v: got %v, want %v within 0.1%%", q, time.Duration(got), time.Duration(want))
+		}
+	}
+	if mean, want := x.Mean(), float64(5000500*time.Nanosecond); math.Abs(mean-want)/want > 0.001 {
+		t.Errorf("mean: got %v, want %v", mean, want)
+	}
+
+	// out of range values are clamped
+	x.Add(-1)
+	x.Add(2 * time.Hour)
+	if x.Min != 0 || x.Max != time.Hour {
+		t.Errorf("clamped: got min %v, max %v", x.Min, x.Max)
+	}
+}
+
+func TestHDRHistogramMerge(t *testing.T) {
+	whole := HDRHistogramMake[time.Duration](1, time.Second, 2)
+	a := HDRHistogramMake[time.Duration](1, time.Second, 2)
+	b := HDRHistogramMake[time.Duration](1, time.Second, 2)
+	for i := time.Duration(1); i <= 1000; i++ {
+		whole.Add(i * time.Millisecond / 2)
+		if i%2 == 0 {
+			a.Add(i * time.Millisecond / 2)
+		} else {
+			b.Add(i * time.Millisecond / 2)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, whole) {
+		t.Error("merged halves differ from the whole")
+	}
+
+	other := HDRHistogramMake[time.Duration](1, time.Second, 3)
+	before := a.Clone()
+	if err := a.Merge(other); err == nil {
+		t.Error("different layouts: got nil error")
+	}
+	if !reflect.DeepEqual(*a, before) {
+		t.Error("failed Merge changed the histogram")
+	}
+}
+
+func TestHDRSampler(t *testing.T) {
+	x := HDRSampler[time.Duration](8, time.Microsecond, time.Minute, 3)
+	Start(x)
+	Sample(x, time.Millisecond)
+	Sample(x, 3*time.Millisecond)
+	s := StopAndCollect(x)
+
+	// decoded histograms derive their layout afresh
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded HDRHistogram[time.Duration]
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Quantile(1); got < 3*time.Millisecond || got > 3003*time.Microsecond {
+		t.Errorf("decoded max quantile: got %v", got)
+	}
+	decoded.Add(2 * time.Millisecond)
+	if decoded.Count != 3 {
+		t.Errorf("decoded count: got %d, want 3", decoded.Count)
+	}
+
+	s.Reset()
+	if s.Count != 0 || s.Quantile(0.5) != 0 || s.Mean() != 0 || len(s.Counts) != len(decoded.Counts) {
+		t.Errorf("after Reset: %+v", s)
+	}
+}
+
+func TestHDRHistogramMakeInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for 6 digits")
+		}
+	}()
+	HDRHistogramMake[int64](1, 1000, 6)
+}
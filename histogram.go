@@ -0,0 +1,63 @@
+package obs
+
+import "sort"
+
+// DefaultBuckets are the bucket upper bounds used by HistogramSampler if none are given.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HistogramState is a histogram of float64 samples.
+// Counts[i] is the number of samples in (Bounds[i-1], Bounds[i]]; the last count holds samples above the highest bound.
+// Add is a sample function, for use with SamplerMakeState. A HistogramState with only Bounds set, or the zero value
+// (a single bucket), is ready to use.
+type HistogramState struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// HistogramStateMake returns an empty histogram with the given bucket upper bounds, which must be sorted.
+func HistogramStateMake(bounds []float64) *HistogramState {
+	o := &HistogramState{
+		Bounds: append([]float64(nil), bounds...),
+	}
+	o.Reset()
+	return o
+}
+
+func (x *HistogramState) Add(v float64) {
+	if len(x.Counts) != len(x.Bounds)+1 {
+		// zero value, or Bounds set directly
+		x.Counts = make([]uint64, len(x.Bounds)+1)
+	}
+
+	i := sort.SearchFloat64s(x.Bounds, v)
+	x.Counts[i]++
+	x.Count++
+	x.Sum += v
+}
+
+func (x *HistogramState) Clone() HistogramState {
+	o := *x
+	o.Counts = append([]uint64(nil), x.Counts...)
+	return o
+}
+
+// Reset clears the aggregate, keeping the bounds.
+func (x *HistogramState) Reset() {
+	*x = HistogramState{
+		Bounds: x.Bounds,
+		Counts: make([]uint64, len(x.Bounds)+1),
+	}
+}
+
+// HistogramSampler returns a Sampler that aggregates samples into a histogram with the given bucket upper bounds,
+// which must be sorted. Uses DefaultBuckets if buckets is nil.
+//
+// Like any Sampler, it is a Loader of its state, in this case a HistogramState.
+func HistogramSampler(queueSize int, buckets []float64) *Sampler[HistogramState, float64] {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return SamplerMakeState(queueSize, HistogramStateMake(buckets), (*HistogramState).Add)
+}
@@ -0,0 +1,52 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistogramSampler(t *testing.T) {
+	x := HistogramSampler(8, []float64{1, 5, 10})
+	Start(x)
+	for _, v := range []float64{0.5, 1, 3, 5, 7, 20, 100} {
+		Sample(x, v)
+	}
+	got := StopAndCollect(x)
+	if want := []uint64{2, 2, 1, 2}; !reflect.DeepEqual(got.Counts, want) {
+		t.Errorf("counts: got %v, want %v", got.Counts, want)
+	}
+	if got.Count != 7 || got.Sum != 136.5 {
+		t.Errorf("got count %d, sum %v", got.Count, got.Sum)
+	}
+
+	c := got.Clone()
+	got.Reset()
+	if c.Count != 7 || c.Counts[0] != 2 {
+		t.Errorf("clone changed by Reset: %+v", c)
+	}
+	if got.Count != 0 || len(got.Counts) != 4 || !reflect.DeepEqual(got.Bounds, []float64{1, 5, 10}) {
+		t.Errorf("after Reset: %+v", got)
+	}
+
+	if d := HistogramSampler(1, nil); len(Snapshot(d).Counts) != len(DefaultBuckets)+1 {
+		t.Errorf("default buckets: got %d counts", len(Snapshot(d).Counts))
+	}
+}
+
+func TestHistogramStateZero(t *testing.T) {
+	var zero HistogramState
+	zero.Add(3)
+	if want := []uint64{1}; !reflect.DeepEqual(zero.Counts, want) || zero.Count != 1 || zero.Sum != 3 {
+		t.Errorf("zero value: got %+v", zero)
+	}
+
+	x := SamplerMakeState(4, &HistogramState{Bounds: []float64{1, 2}}, (*HistogramState).Add)
+	Start(x)
+	for _, v := range []float64{0.5, 1.5, 5} {
+		Sample(x, v)
+	}
+	state := StopAndCollect(x)
+	if want := []uint64{1, 1, 1}; !reflect.DeepEqual(state.Counts, want) || state.Count != 3 {
+		t.Errorf("bounds only: got %+v", state)
+	}
+}
@@ -0,0 +1,143 @@
+package obs
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// An exportFormat is a way of rendering Map members over HTTP.
+type exportFormat struct {
+	contentType string
+	write       func(io.Writer, []Value) error
+	level       Visibility // highest Visibility served
+}
+
+var exportFormats = map[string]exportFormat{
+	"json":        {"application/json", streamJSON, Debug},
+	"prometheus":  {"text/plain; version=0.0.4; charset=utf-8", writePrometheus, Public},
+	"openmetrics": {"application/openmetrics-text; version=1.0.0; charset=utf-8", writeOpenMetrics, Public},
+}
+
+// FormatHandler returns a handler that serves the contents of a Map in the format requested by the client:
+// the "format" query parameter ("json", "prometheus" or "openmetrics") if present, otherwise the Accept header.
+// Defaults to JSON.
+// JSON includes members of any Visibility, while the metrics formats only include Public ones.
+func FormatHandler(m *Map) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveFormat(w, r, func() []Value {
+			return metrics(m)
+		})
+	})
+}
+
+// serveFormat renders the given members in the format requested by the client, as described by FormatHandler.
+// The members are only obtained if the format is valid.
+func serveFormat(w http.ResponseWriter, r *http.Request, values func() []Value) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = negotiate(r.Header.Get("Accept"))
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, "unknown format: "+name, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	if err := format.write(w, visible(values(), format.level)); err != nil {
+		warn("obs: export failed", "format", name, "err", err)
+	}
+}
+
+// A Throttle serves the contents of a Map like a FormatHandler, but from a snapshot that is refreshed at most once
+// per interval, sparing expensive Loaders from rapid or concurrent scrapes.
+// Requests arriving while the snapshot is stale wait for a single refresh.
+type Throttle struct {
+	Clock Clock // time source for snapshot expiry; the real clock if nil
+
+	cache *CachedLoader
+}
+
+// ThrottledHandler returns a handler serving snapshots of a Map taken at most once per minInterval.
+func ThrottledHandler(m *Map, minInterval time.Duration) *Throttle {
+	return &Throttle{
+		cache: Cached(minInterval, mapSnapshot{m}),
+	}
+}
+
+func (x *Throttle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveFormat(w, r, func() []Value {
+		return x.cache.load(x.Clock).([]Value)
+	})
+}
+
+// mapSnapshot is a Loader of the members of a Map with their values loaded, as a []Value sorted by label.
+type mapSnapshot struct {
+	m *Map
+}
+
+func (x mapSnapshot) Load() any {
+	values := metrics(x.m)
+	o := values[:0]
+	for _, v := range values {
+		loaded, ok := safeLoad(v)
+		if !ok {
+			continue
+		}
+
+		c := frozen{v: loaded}
+		if _, ok := v.Loader.(Numeric); ok {
+			c.numeric = true
+			c.f, c.ok = loadFloat(v)
+		}
+		if k, ok := v.Loader.(Kinded); ok {
+			v.Loader = kindedFrozen{c, k.Kind()}
+		} else {
+			v.Loader = c
+		}
+		o = append(o, v)
+	}
+	return o
+}
+
+// frozen is a Loader of a previously loaded value, retaining what exporters need of the original.
+type frozen struct {
+	v       any
+	numeric bool // whether the original was Numeric, with f and ok being its result
+	f       float64
+	ok      bool
+}
+
+func (x frozen) AsFloat64() (float64, bool) {
+	if x.numeric {
+		return x.f, x.ok
+	}
+	return toFloat(x.v)
+}
+
+func (x frozen) Load() any {
+	return x.v
+}
+
+type kindedFrozen struct {
+	frozen
+	kind string
+}
+
+func (x kindedFrozen) Kind() string {
+	return x.kind
+}
+
+// negotiate picks an export format name from an Accept header.
+// OpenMetrics is preferred over the Prometheus text format, as Prometheus scrapers offer both.
+func negotiate(accept string) string {
+	switch {
+	case strings.Contains(accept, "application/openmetrics-text"):
+		return "openmetrics"
+	case strings.Contains(accept, "text/plain"):
+		return "prometheus"
+	}
+	return "json"
+}
@@ -0,0 +1,62 @@
+package obs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormatHandler(t *testing.T) {
+	m := MapOf(
+		Value{Label: "up", Loader: constant(1)},
+		Value{Label: "internal", Loader: constant(2), Visibility: Debug},
+	)
+	srv := httptest.NewServer(FormatHandler(m))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		query, accept string
+		status        int
+		contentType   string
+		body          string
+	}{
+		{"", "", http.StatusOK, "application/json", `{"internal":2,"up":1}`},
+		{"", "text/plain;version=0.0.4", http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", "# TYPE up gauge\nup 1\n"},
+		{"", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5", http.StatusOK, "application/openmetrics-text; version=1.0.0; charset=utf-8", "# TYPE up gauge\nup 1\n# EOF\n"},
+		{"?format=prometheus", "application/json", http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", "# TYPE up gauge\nup 1\n"},
+		{"?format=json", "text/plain", http.StatusOK, "application/json", `{"internal":2,"up":1}`},
+		{"?format=xml", "", http.StatusBadRequest, "", ""},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+c.query, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name := c.query + " " + c.accept
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: status %d, want %d", name, resp.StatusCode, c.status)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); got != c.contentType {
+			t.Errorf("%s: content type %q, want %q", name, got, c.contentType)
+		}
+		if got := body.String(); got != c.body {
+			t.Errorf("%s: got %q, want %q", name, got, c.body)
+		}
+	}
+}
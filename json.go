@@ -0,0 +1,107 @@
+package obs
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// A Kinded Loader declares the kind of metric it provides, such as "counter", "gauge" or "histogram".
+type Kinded interface {
+	Kind() string
+}
+
+// StreamJSON writes the loaded members of a Map to w as a single JSON object, keyed by label in sorted order,
+// with the Tags of tagged members appended to their keys in OpenMetrics label syntax (e.g. `requests{code="200"}`).
+// Members with a Kinded Loader are written as {"type": kind, "value": value} objects, the rest as bare values.
+// Values that can't be encoded, such as non-finite floats, are written as null, and reported through the package Logger.
+// Unlike encoding a full snapshot, values are loaded and encoded one at a time, bounding memory use for large Maps.
+// The output is the same as that of the Map's MarshalJSON.
+// If several members share a label and tags, only one of them is written.
+// All members are written regardless of Visibility, making it suitable for debug dumps; see WriteFormat for filtering.
+func StreamJSON(w io.Writer, m *Map) error {
+	return streamJSON(w, metrics(m))
+}
+
+func streamJSON(w io.Writer, values []Value) error {
+	return writeJSON(w, values, true)
+}
+
+// writeJSON is the JSON encoder of Maps, writing the given members as a single object, as described by StreamJSON.
+// Kinded members are written as kindedValues if kinds is set, and as bare values otherwise.
+// Only writer errors are returned, so the output is always a complete object unless the writer fails.
+func writeJSON(w io.Writer, values []Value, kinds bool) error {
+	keys, values := taggedLabels(values)
+
+	b := bufio.NewWriter(w)
+	b.WriteByte('{')
+
+	first := true
+	last := ""
+	for i, v := range values {
+		if !first && keys[i] == last {
+			continue
+		}
+
+		loaded, ok := safeLoad(v)
+		if !ok {
+			continue
+		}
+		data := encodeJSON(v.Label, loaded)
+		if k, ok := v.Loader.(Kinded); ok && kinds {
+			data, _ = json.Marshal(kindedValue{k.Kind(), json.RawMessage(data)})
+		}
+		key, _ := json.Marshal(keys[i])
+
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		last = keys[i]
+
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(data)
+	}
+
+	b.WriteByte('}')
+	return b.Flush()
+}
+
+// encodeJSON encodes a loaded value, substituting null if it can't be encoded (e.g. a NaN), after reporting it.
+func encodeJSON(label string, v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		warn("obs: value not encodable", "label", label, "err", err)
+		return []byte("null")
+	}
+	return data
+}
+
+// taggedLabels returns the keys of the given members, as by taggedLabel, along with the members reordered by key.
+// The input is left untouched, as it may be a shared snapshot.
+func taggedLabels(values []Value) ([]string, []Value) {
+	keys := make([]string, len(values))
+	order := make([]int, len(values))
+	for i, v := range values {
+		keys[i] = taggedLabel(v.Label, v.Tags)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+
+	sortedKeys := make([]string, len(values))
+	sorted := make([]Value, len(values))
+	for i, j := range order {
+		sortedKeys[i] = keys[j]
+		sorted[i] = values[j]
+	}
+	return sortedKeys, sorted
+}
+
+type kindedValue struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
@@ -0,0 +1,90 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func jsonMap() *Map {
+	m := MapMake()
+	c := &Counter{}
+	c.Add(2)
+	m.Set("c", Value{Label: "count", Loader: c})
+	m.Set("g", Value{Label: "gauge", Loader: &Gauge{}})
+	m.Set("s", Value{Label: "name", Loader: LoaderFunc[string](func() string { return "x" })})
+	m.Set("t", Value{Label: "tagged", Loader: LoaderFunc[int](func() int { return 1 }), Tags: map[string]string{"a": "b"}})
+	m.Child("sub").Set("x", Value{Label: "nested", Loader: LoaderFunc[[]int](func() []int { return []int{1, 2} })})
+	return m
+}
+
+func TestStreamJSONEqualsMarshalJSON(t *testing.T) {
+	m := jsonMap()
+
+	var streamed bytes.Buffer
+	if err := StreamJSON(&streamed, m); err != nil {
+		t.Fatal(err)
+	}
+	batch, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != string(batch) {
+		t.Errorf("streamed output differs from MarshalJSON:\n%s\n%s", streamed.String(), batch)
+	}
+}
+
+func TestStreamJSONShapes(t *testing.T) {
+	var b bytes.Buffer
+	if err := StreamJSON(&b, jsonMap()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := got["count"].(map[string]any); !ok || c["type"] != "counter" || c["value"] != 2.0 {
+		t.Errorf("typed metric: got %v", got["count"])
+	}
+	if got["name"] != "x" {
+		t.Errorf("untyped value: got %v", got["name"])
+	}
+}
+
+func TestStreamJSONNonFinite(t *testing.T) {
+	m := MapMake()
+	m.Set("nan", Value{Label: "nan", Loader: LoaderFunc[float64](func() float64 { return math.NaN() })})
+	m.Set("inf", Value{Label: "inf", Loader: LoaderFunc[float64](func() float64 { return math.Inf(1) })})
+	m.Set("ok", Value{Label: "ok", Loader: LoaderFunc[float64](func() float64 { return 1 })})
+
+	var b bytes.Buffer
+	if err := StreamJSON(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `{"inf":null,"nan":null,"ok":1}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	data, err := m.SnapshotJSON()
+	if err != nil || string(data) != `{"inf":null,"nan":null,"ok":1}` {
+		t.Errorf("SnapshotJSON: got %s, %v", data, err)
+	}
+}
+
+func TestStreamJSONTags(t *testing.T) {
+	m := MapMake()
+	m.Set(1, Value{Label: "requests", Loader: constant(1), Tags: map[string]string{"code": "200"}})
+	m.Set(2, Value{Label: "requests", Loader: constant(2), Tags: map[string]string{"code": "500"}})
+	m.Set(3, Value{Label: "up", Loader: constant(true)})
+
+	var b bytes.Buffer
+	if err := StreamJSON(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"requests{code=\"200\"}":1,"requests{code=\"500\"}":2,"up":true}`
+	if got := b.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
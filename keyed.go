@@ -0,0 +1,45 @@
+package obs
+
+// A Keyed sample carries the key of the aggregation it belongs to.
+type Keyed[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// A KeyedSampler maintains a separate aggregation state for every sample key, created on first sight.
+// The usual Sampler functions apply to its embedded Sampler.
+type KeyedSampler[K comparable, V any, S any] struct {
+	*Sampler[map[K]*S, Keyed[K, V]]
+}
+
+func KeyedSamplerMake[K comparable, V any, S any](queueSize int, sampleFunc func(*S, V)) *KeyedSampler[K, V, S] {
+	x := SamplerMake(queueSize, func(s *map[K]*S, v Keyed[K, V]) {
+		if *s == nil {
+			*s = make(map[K]*S)
+		}
+
+		state, ok := (*s)[v.Key]
+		if !ok {
+			state = new(S)
+			(*s)[v.Key] = state
+		}
+		sampleFunc(state, v.Value)
+	})
+	return &KeyedSampler[K, V, S]{x}
+}
+
+// SampleKey pushes a new sample for the given key.
+func SampleKey[K comparable, V any, S any](x *KeyedSampler[K, V, S], key K, v V) {
+	Sample(x.Sampler, Keyed[K, V]{key, v})
+}
+
+// Load returns a (shallow) copy of the per key states, as a map[K]any.
+func (x *KeyedSampler[K, V, S]) Load() any {
+	x.stateMux.Lock()
+	o := make(map[K]any, len(*x.state))
+	for k, v := range *x.state {
+		o[k] = *v
+	}
+	x.stateMux.Unlock()
+	return o
+}
@@ -0,0 +1,25 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyedSampler(t *testing.T) {
+	x := KeyedSamplerMake[string, int, int](8, func(s *int, v int) { *s += v })
+	Start(x.Sampler)
+	SampleKey(x, "/a", 1)
+	SampleKey(x, "/b", 10)
+	SampleKey(x, "/a", 2)
+	Flush(x.Sampler)
+
+	if got, want := x.Load(), map[string]any{"/a": 3, "/b": 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	SampleKey(x, "/c", 5)
+	StopAndWait(x.Sampler)
+	if got, want := x.Load(), map[string]any{"/a": 3, "/b": 10, "/c": 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("new key: got %v, want %v", got, want)
+	}
+}
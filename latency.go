@@ -0,0 +1,121 @@
+package obs
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the bucket upper bounds used by LatencySampler if none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// FineLatencyBuckets span 100ns to 100ms, for timing operations that are expected to be fast, such as the
+// internal measurements of EnqueueWaits and ProcessingTime.
+var FineLatencyBuckets = []time.Duration{
+	100 * time.Nanosecond,
+	250 * time.Nanosecond,
+	500 * time.Nanosecond,
+	time.Microsecond,
+	2500 * time.Nanosecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// LatencyState is a latency histogram.
+// Counts[i] is the number of samples in (Bounds[i-1], Bounds[i]]; the last count holds samples above the highest bound.
+type LatencyState struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func (x *LatencyState) Clone() LatencyState {
+	o := *x
+	o.Counts = append([]uint64(nil), x.Counts...)
+	return o
+}
+
+// Reset clears the aggregate, keeping the bounds.
+func (x *LatencyState) Reset() {
+	*x = LatencyState{
+		Bounds: x.Bounds,
+		Counts: make([]uint64, len(x.Bounds)+1),
+	}
+}
+
+func (x *LatencyState) Add(v time.Duration) {
+	i := sort.Search(len(x.Bounds), func(i int) bool {
+		return v <= x.Bounds[i]
+	})
+	x.Counts[i]++
+	x.Count++
+	x.Sum += v
+}
+
+// LatencySampler returns a Sampler that aggregates durations into a histogram with the given bucket upper bounds,
+// which must be sorted. Uses DefaultLatencyBuckets if buckets is nil.
+//
+// Like any Sampler, it is a Loader of its state, in this case a LatencyState.
+func LatencySampler(queueSize int, buckets []time.Duration) *Sampler[LatencyState, time.Duration] {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+
+	state := &LatencyState{
+		Bounds: append([]time.Duration(nil), buckets...),
+	}
+	state.Reset()
+	return SamplerMakeState(queueSize, state, (*LatencyState).Add)
+}
+
+// durations is a concurrent safe Loader of a LatencyState with FineLatencyBuckets, used for internal instrumentation.
+type durations struct {
+	state LatencyState
+	mux   sync.Mutex
+}
+
+func (x *durations) Load() any {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	x.init()
+	return x.state.Clone()
+}
+
+func (x *durations) add(d time.Duration) {
+	x.mux.Lock()
+	x.init()
+	x.state.Add(d)
+	x.mux.Unlock()
+}
+
+func (x *durations) init() {
+	if x.state.Counts == nil {
+		x.state.Bounds = FineLatencyBuckets
+		x.state.Reset()
+	}
+}
@@ -0,0 +1,94 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProcessingTimeResolution(t *testing.T) {
+	x := SamplerMake(16, func(s *int, v int) {
+		time.Sleep(time.Duration(v) * time.Microsecond)
+	})
+	x.MeasureProcessing = true
+	Start(x)
+	for i := 0; i < 5; i++ {
+		Sample(x, 200)
+	}
+	StopAndWait(x)
+
+	state := ProcessingTime(x).Load().(LatencyState)
+	if state.Count != 5 {
+		t.Fatalf("got %d measurements, want 5", state.Count)
+	}
+	if state.Counts[0] != 0 {
+		t.Errorf("200µs calls fell into the lowest bucket: %v", state.Counts)
+	}
+	if state.Sum < 5*200*time.Microsecond {
+		t.Errorf("measured total %v, want at least 1ms", state.Sum)
+	}
+}
+
+func TestLatencySampler(t *testing.T) {
+	ms := time.Millisecond
+	x := LatencySampler(16, []time.Duration{10 * ms, 100 * ms})
+	Start(x)
+	for _, d := range []time.Duration{ms, 10 * ms, 11 * ms, 50 * ms, time.Second} {
+		Sample(x, d)
+	}
+	StopAndWait(x)
+
+	state, ok := x.Load().(LatencyState)
+	if !ok {
+		t.Fatalf("loaded %T, want a LatencyState", x.Load())
+	}
+	if want := []uint64{2, 2, 1}; !reflect.DeepEqual(state.Counts, want) {
+		t.Errorf("counts: got %v, want %v", state.Counts, want)
+	}
+	if state.Count != 5 || state.Sum != 1072*ms {
+		t.Errorf("got count %d, sum %v", state.Count, state.Sum)
+	}
+
+	y := LatencySampler(1, nil)
+	if got := Snapshot(y); !reflect.DeepEqual(got.Bounds, DefaultLatencyBuckets) || len(got.Counts) != len(DefaultLatencyBuckets)+1 {
+		t.Errorf("default buckets: got %v, %d counts", got.Bounds, len(got.Counts))
+	}
+}
+
+func TestEnqueueWaits(t *testing.T) {
+	x := SamplerMake(1, func(s *int, v int) {
+		time.Sleep(time.Millisecond)
+	})
+	x.MeasureWaits = true
+	Start(x)
+	for i := 0; i < 5; i++ {
+		Sample(x, i)
+	}
+	StopAndWait(x)
+
+	state := EnqueueWaits(x).Load().(LatencyState)
+	if state.Count == 0 || state.Sum <= 0 {
+		t.Fatalf("recorded %d waits totalling %v, want some behind a slow consumer", state.Count, state.Sum)
+	}
+	// waits of about a millisecond stay clear of the lowest FineLatencyBuckets
+	var fast uint64
+	for i, bound := range state.Bounds {
+		if bound < 10*time.Microsecond {
+			fast += state.Counts[i]
+		}
+	}
+	if fast == state.Count {
+		t.Errorf("all waits under 10µs: %v", state.Counts)
+	}
+}
+
+func TestLatencyStateAdd(t *testing.T) {
+	ms := time.Millisecond
+	state := LatencyState{Bounds: []time.Duration{10 * ms}}
+	state.Reset()
+	state.Add(5 * ms)
+	state.Add(time.Second)
+	if want := []uint64{1, 1}; !reflect.DeepEqual(state.Counts, want) || state.Count != 2 || state.Sum != 1005*ms {
+		t.Errorf("got %+v", state)
+	}
+}
@@ -0,0 +1,93 @@
+package obs
+
+import "sync/atomic"
+
+// A LoadableInt64 is an int64 that may go up and down, for plain values like connection counts.
+// The zero value is ready to use, and holds 0. Use a Counter for totals that only increase.
+//
+// Its methods are concurrent safe.
+type LoadableInt64 struct {
+	n atomic.Int64
+}
+
+// MapSetInt64 returns a new LoadableInt64, registered in m under the given key and label.
+func MapSetInt64(m *Map, key any, label string) *LoadableInt64 {
+	x := &LoadableInt64{}
+	m.Set(key, Value{Label: label, Loader: x})
+	return x
+}
+
+func (x *LoadableInt64) Add(delta int64) {
+	x.n.Add(delta)
+}
+
+func (x *LoadableInt64) AsFloat64() (float64, bool) {
+	return float64(x.n.Load()), true
+}
+
+func (x *LoadableInt64) Kind() string {
+	return "gauge"
+}
+
+// Load returns the current value as an int64.
+func (x *LoadableInt64) Load() any {
+	return x.n.Load()
+}
+
+func (x *LoadableInt64) Set(v int64) {
+	x.n.Store(v)
+}
+
+func (x *LoadableInt64) Value() int64 {
+	return x.n.Load()
+}
+
+// A LoadableFloat64 is a float64 that may go up and down.
+// It is the same type as Gauge, named for symmetry with the other Loadables.
+type LoadableFloat64 = Gauge
+
+// MapSetFloat64 returns a new LoadableFloat64, registered in m under the given key and label.
+func MapSetFloat64(m *Map, key any, label string) *LoadableFloat64 {
+	x := &LoadableFloat64{}
+	m.Set(key, Value{Label: label, Loader: x})
+	return x
+}
+
+// A LoadableBool is a flag, exported numerically as 1 or 0.
+// The zero value is ready to use, and holds false.
+//
+// Its methods are concurrent safe.
+type LoadableBool struct {
+	b atomic.Bool
+}
+
+// MapSetBool returns a new LoadableBool, registered in m under the given key and label.
+func MapSetBool(m *Map, key any, label string) *LoadableBool {
+	x := &LoadableBool{}
+	m.Set(key, Value{Label: label, Loader: x})
+	return x
+}
+
+func (x *LoadableBool) AsFloat64() (float64, bool) {
+	if x.b.Load() {
+		return 1, true
+	}
+	return 0, true
+}
+
+func (x *LoadableBool) Kind() string {
+	return "gauge"
+}
+
+// Load returns the current value as a bool.
+func (x *LoadableBool) Load() any {
+	return x.b.Load()
+}
+
+func (x *LoadableBool) Set(v bool) {
+	x.b.Store(v)
+}
+
+func (x *LoadableBool) Value() bool {
+	return x.b.Load()
+}
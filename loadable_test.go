@@ -0,0 +1,53 @@
+package obs
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestLoadables(t *testing.T) {
+	m := MapMake()
+	conns := MapSetInt64(m, "conns", "connections")
+	ratio := MapSetFloat64(m, "ratio", "hit_ratio")
+	ready := MapSetBool(m, "ready", "ready")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				conns.Add(2)
+				conns.Add(-1)
+			}
+		}()
+	}
+	wg.Wait()
+	if conns.Value() != 800 || conns.Load() != int64(800) {
+		t.Errorf("int64: got %v", conns.Load())
+	}
+	ratio.Set(0.25)
+	ready.Set(true)
+	if !ready.Value() || ready.Load() != true {
+		t.Errorf("bool: got %v", ready.Load())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFormat(&buf, m, "prometheus", Public); err != nil {
+		t.Fatal(err)
+	}
+	want := "# TYPE connections gauge\nconnections 800\n# TYPE hit_ratio gauge\nhit_ratio 0.25\n# TYPE ready gauge\nready 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ready.Set(false)
+	conns.Set(-3)
+	if f, _ := ready.AsFloat64(); f != 0 {
+		t.Errorf("false exported as %v", f)
+	}
+	if f, _ := conns.AsFloat64(); f != -3 {
+		t.Errorf("after Set: got %v", f)
+	}
+}
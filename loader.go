@@ -0,0 +1,184 @@
+package obs
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// A CachedLoader wraps another Loader, reusing its last result until it becomes stale.
+//
+// Its methods are concurrent safe. Concurrent loads of a stale value wait for a single refresh.
+type CachedLoader struct {
+	Clock Clock // time source for expiry; the real clock if nil
+
+	inner Loader
+	ttl   time.Duration
+
+	value  any
+	expiry time.Time
+	loaded bool
+	mux    sync.RWMutex
+}
+
+// Cached returns a Loader that only calls inner once its last result is older than ttl.
+func Cached(ttl time.Duration, inner Loader) *CachedLoader {
+	return &CachedLoader{
+		inner: inner,
+		ttl:   ttl,
+	}
+}
+
+func (x *CachedLoader) Load() any {
+	return x.load(x.Clock)
+}
+
+// load is Load with the given time source.
+func (x *CachedLoader) load(clock Clock) any {
+	x.mux.RLock()
+	if x.loaded && clockOr(clock).Now().Before(x.expiry) {
+		o := x.value
+		x.mux.RUnlock()
+		return o
+	}
+	x.mux.RUnlock()
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	// another caller might have refreshed in the meantime
+	now := clockOr(clock).Now()
+	if !x.loaded || !now.Before(x.expiry) {
+		x.value = x.inner.Load()
+		x.expiry = now.Add(x.ttl)
+		x.loaded = true
+	}
+	return x.value
+}
+
+// A Refresher Loader caches its value, and can be made to renew it ahead of schedule.
+// CachedLoader is a Refresher.
+type Refresher interface {
+	Refresh()
+}
+
+// Refresh reloads the inner Loader now, regardless of the age of the cached result.
+// Like stale loads, it holds up concurrent Loads and Refreshes until done, so the inner Loader is never called concurrently.
+func (x *CachedLoader) Refresh() {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	x.value = x.inner.Load()
+	x.expiry = clockOr(x.Clock).Now().Add(x.ttl)
+	x.loaded = true
+}
+
+// Scaled returns a Loader of the inner Loader's numeric values multiplied by factor, as float64s,
+// for unit conversions at export (e.g. nanoseconds to seconds with a factor of 1e-9).
+// time.Durations count as numeric, in nanoseconds. Non-numeric values, including booleans, are passed through unchanged.
+func Scaled(inner Loader, factor float64) Loader {
+	return scaled{inner, factor}
+}
+
+type scaled struct {
+	inner  Loader
+	factor float64
+}
+
+func (x scaled) AsFloat64() (float64, bool) {
+	if n, ok := x.inner.(Numeric); ok {
+		f, ok := n.AsFloat64()
+		return f * x.factor, ok
+	}
+	f, ok := x.scale(x.inner.Load())
+	return f, ok
+}
+
+func (x scaled) Load() any {
+	v := x.inner.Load()
+	if f, ok := x.scale(v); ok {
+		return f
+	}
+	return v
+}
+
+func (x scaled) scale(v any) (float64, bool) {
+	switch v := v.(type) {
+	case bool:
+		return 0, false
+	case time.Duration:
+		return float64(v) * x.factor, true
+	}
+	f, ok := toFloat(v)
+	return f * x.factor, ok
+}
+
+// StructLoader returns a Loader of the exported fields of the struct pointed to by ptr, as a map[string]any keyed by field name.
+// A field tagged `obs:"name"` is keyed by the given name instead, and one tagged `obs:"-"` is left out.
+// Nested structs, and non-nil pointers to structs, are loaded as nested maps,
+// unless they marshal themselves to text or JSON (like time.Time), in which case they are loaded as they are.
+//
+// Fields are read by reflection on every Load, which is much slower than a dedicated Loader,
+// and without synchronization, so the struct must not be modified concurrently.
+// Panics if ptr is not a pointer to a struct.
+func StructLoader(ptr any) Loader {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		panic("obs: StructLoader requires a pointer to a struct")
+	}
+	return structLoader{v.Elem()}
+}
+
+type structLoader struct {
+	v reflect.Value
+}
+
+func (x structLoader) Load() any {
+	return loadStruct(x.v)
+}
+
+func loadStruct(v reflect.Value) map[string]any {
+	t := v.Type()
+	o := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("obs"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && !selfMarshaling(fv.Type()) {
+			o[name] = loadStruct(fv)
+		} else {
+			o[name] = fv.Interface()
+		}
+	}
+	return o
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// selfMarshaling reports whether values of type t, or pointers to them, provide their own encoding.
+func selfMarshaling(t reflect.Type) bool {
+	for _, t := range []reflect.Type{t, reflect.PointerTo(t)} {
+		if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+			return true
+		}
+	}
+	return false
+}
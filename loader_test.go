@@ -0,0 +1,157 @@
+package obs_test
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+// TestCachedLoaderSingleFlight checks that concurrent Loads and Refreshes never call the inner Loader concurrently.
+func TestCachedLoaderSingleFlight(t *testing.T) {
+	var active, peak, calls atomic.Int32
+	inner := obs.LoaderFunc[int](func() int {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return int(calls.Add(1))
+	})
+	x := obs.Cached(0, inner) // always stale
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			x.Refresh()
+		}()
+		go func() {
+			defer wg.Done()
+			x.Load()
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p != 1 {
+		t.Errorf("inner Loader ran %d times concurrently", p)
+	}
+	if got, want := x.Load(), int(calls.Load()); got != want {
+		t.Errorf("Load after Refresh: got %v, want the latest result %v", got, want)
+	}
+}
+
+func TestCachedLoader(t *testing.T) {
+	var calls int
+	x := obs.Cached(time.Minute, obs.LoaderFunc[int](func() int {
+		calls++
+		return calls
+	}))
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x.Clock = clock
+
+	if v := x.Load(); v != 1 {
+		t.Fatalf("first Load: got %v", v)
+	}
+	clock.Advance(59 * time.Second)
+	if v := x.Load(); v != 1 {
+		t.Errorf("within the TTL: got %v, want the cached 1", v)
+	}
+	clock.Advance(time.Second)
+	if v := x.Load(); v != 2 {
+		t.Errorf("after expiry: got %v, want a fresh 2", v)
+	}
+
+	x.Refresh()
+	if v := x.Load(); v != 3 {
+		t.Errorf("after Refresh: got %v, want 3", v)
+	}
+	clock.Advance(59 * time.Second)
+	if v := x.Load(); v != 3 {
+		t.Errorf("Refresh restarts the TTL: got %v, want 3", v)
+	}
+}
+
+func TestStructLoader(t *testing.T) {
+	type inner struct {
+		Depth int
+	}
+	type config struct {
+		Name    string
+		Port    int    `obs:"listen_port"`
+		Secret  string `obs:"-"`
+		private int
+		Limits  inner
+		Backup  *inner
+		Missing *inner
+		Started time.Time
+	}
+	at := time.Unix(1, 0)
+	c := config{Name: "srv", Port: 80, Secret: "x", private: 1, Limits: inner{3}, Backup: &inner{4}, Started: at}
+
+	v := obs.StructLoader(&c)
+	want := map[string]any{
+		"Name":        "srv",
+		"listen_port": 80,
+		"Limits":      map[string]any{"Depth": 3},
+		"Backup":      map[string]any{"Depth": 4},
+		"Missing":     (*inner)(nil),
+		"Started":     at,
+	}
+	if got := v.Load(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	c.Limits.Depth = 5
+	if got := v.Load().(map[string]any)["Limits"]; !reflect.DeepEqual(got, map[string]any{"Depth": 5}) {
+		t.Errorf("after change: got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("accepted a non-pointer")
+		}
+	}()
+	obs.StructLoader(c)
+}
+
+func TestScaled(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    any
+		load  any
+		float bool
+	}{
+		{"int", 3, 1.5, true},
+		{"uint64", uint64(8), 4.0, true},
+		{"float", 0.5, 0.25, true},
+		{"duration", 2 * time.Second, 1e9, true},
+		{"bool", true, true, false},
+		{"string", "up", "up", false},
+	}
+	for _, c := range cases {
+		x := obs.Scaled(constant(c.in), 0.5)
+		if got := x.Load(); got != c.load {
+			t.Errorf("%s: Load got %v (%T), want %v (%T)", c.name, got, got, c.load, c.load)
+		}
+		f, ok := x.(obs.Numeric).AsFloat64()
+		if ok != c.float || ok && f != c.load {
+			t.Errorf("%s: AsFloat64 got %v, %t", c.name, f, ok)
+		}
+	}
+
+	// nanoseconds to seconds
+	x := obs.Scaled(constant(1500*time.Millisecond), 1e-9)
+	if got := x.Load(); got != 1.5 {
+		t.Errorf("seconds: got %v, want 1.5", got)
+	}
+}
@@ -0,0 +1,15 @@
+package obs
+
+import "log/slog"
+
+// Logger receives the package's diagnostic reports.
+// If nil, slog.Default() is used.
+var Logger *slog.Logger
+
+func warn(msg string, args ...any) {
+	l := Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	l.Warn(msg, args...)
+}
@@ -0,0 +1,16 @@
+package obs
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+// captureLog redirects the package Logger to the returned buffer for the duration of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var b bytes.Buffer
+	prev := Logger
+	Logger = slog.New(slog.NewTextHandler(&b, nil))
+	t.Cleanup(func() { Logger = prev })
+	return &b
+}
@@ -0,0 +1,573 @@
+package obs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Map groups and provides access to a set of Values.
+//
+// The zero value is an empty Map, ready to use. Its methods are concurrent safe.
+// Reads never block: they work on an immutable snapshot of the contents, which writers replace with a modified copy.
+// This makes every write O(n) in the size of the Map, which suits the usual pattern of rare registrations and frequent scrapes.
+type Map struct {
+	Clock Clock // time source for Set times; the real clock if nil
+
+	values   atomic.Pointer[map[any]Value] // never modified in place
+	times    map[any]time.Time             // last Set time of each key; nil if not tracked
+	validate func(label string) error      // label check on Set; nil if not strict
+	mux      sync.Mutex                    // serializes writers
+
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+
+	reaper *reaper // nil if members don't expire
+
+	children atomic.Pointer[map[string]*Map] // never modified in place; nil if there are none
+}
+
+// A reaper periodically deletes stale members of a Map.
+type reaper struct {
+	ttl      time.Duration
+	interval time.Duration
+	started  bool
+	closed   bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func MapMake() *Map {
+	x := &Map{}
+	values := make(map[any]Value)
+	x.values.Store(&values)
+	return x
+}
+
+// MapMakeTimed returns a Map that records when each key was last Set, enabling staleness checks.
+func MapMakeTimed() *Map {
+	x := MapMake()
+	x.times = make(map[any]time.Time)
+	return x
+}
+
+// MapMakeExpiring returns a timed Map that deletes members not Set within ttl, checking every interval.
+// The checks are performed by a goroutine launched on the first Set (so any Clock must be assigned before then),
+// which must be terminated with CloseMap when the Map is no longer needed.
+func MapMakeExpiring(ttl, interval time.Duration) *Map {
+	x := MapMakeTimed()
+	x.reaper = &reaper{
+		ttl:      ttl,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	return x
+}
+
+// CloseMap terminates the expiry goroutine of a Map made by MapMakeExpiring, and waits for it to exit.
+// Members no longer expire afterwards. NoOp for other Maps, and on subsequent calls.
+func CloseMap(x *Map) {
+	x.mux.Lock()
+	r := x.reaper
+	if r == nil || r.closed {
+		x.mux.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.done)
+	x.mux.Unlock()
+
+	r.wg.Wait()
+}
+
+// MapMakeStrict returns a Map that validates the labels of Values when they are Set, and panics if they are rejected.
+// This surfaces naming mistakes at registration, rather than at export.
+// See PrometheusValid and GraphiteValid for predefined validators.
+func MapMakeStrict(validate func(label string) error) *Map {
+	x := MapMake()
+	x.validate = validate
+	return x
+}
+
+// MapOf returns a Map containing the given Values, keyed by their labels.
+// If several Values share a label, the last one wins.
+func MapOf(values ...Value) *Map {
+	x := MapMake()
+	m := make(map[any]Value, len(values))
+	for _, v := range values {
+		m[v.Label] = v
+	}
+	x.values.Store(&m)
+	return x
+}
+
+// ChildSeparator joins the names of child Maps to the labels of their members.
+const ChildSeparator = "."
+
+// Child returns the child Map of the given name, creating it if needed.
+// The members of a child appear in its parent (and further ancestors) with their labels prefixed by the child's name
+// and ChildSeparator, when read by Range, Snapshot and the other methods looking at loaded values, as well as by exporters.
+// They are not accessible by key through the parent, where Snapshot reports them under opaque keys,
+// and are not affected by its writes.
+//
+// A new child is strict if the parent is, with the same validation, which only sees its own unprefixed labels.
+func (x *Map) Child(name string) *Map {
+	if children := x.children.Load(); children != nil {
+		if c, ok := (*children)[name]; ok {
+			return c
+		}
+	}
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	children := make(map[string]*Map)
+	if old := x.children.Load(); old != nil {
+		if c, ok := (*old)[name]; ok {
+			return c
+		}
+		for k, c := range *old {
+			children[k] = c
+		}
+	}
+
+	c := MapMake()
+	c.validate = x.validate
+	children[name] = c
+	x.children.Store(&children)
+	return c
+}
+
+func (x *Map) Delete(key any) {
+	x.deletes.Add(1)
+
+	x.mux.Lock()
+	if _, ok := x.load()[key]; ok {
+		values := x.clone()
+		delete(values, key)
+		x.values.Store(&values)
+	}
+	delete(x.times, key)
+	x.mux.Unlock()
+}
+
+// DeleteStale removes all members that have not been Set within the given duration, and returns their number.
+// NoOp on Maps that don't track Set times.
+func (x *Map) DeleteStale(olderThan time.Duration) int {
+	cutoff := clockOr(x.Clock).Now().Add(-olderThan)
+
+	x.mux.Lock()
+	var values map[any]Value
+	for k, t := range x.times {
+		if t.Before(cutoff) {
+			if values == nil {
+				values = x.clone()
+			}
+			delete(values, k)
+			delete(x.times, k)
+		}
+	}
+	o := 0
+	if values != nil {
+		o = len(x.load()) - len(values)
+		x.values.Store(&values)
+		x.deletes.Add(uint64(o))
+	}
+	x.mux.Unlock()
+	return o
+}
+
+// Filter returns the loaded values of all members whose labels satisfy pred, keyed by label.
+func (x *Map) Filter(pred func(label string) bool) map[string]any {
+	o := make(map[string]any)
+	for _, v := range x.all() {
+		if !pred(v.Label) {
+			continue
+		}
+		if loaded, ok := safeLoad(v); ok {
+			o[v.Label] = loaded
+		}
+	}
+	return o
+}
+
+// ForEach calls the given function with the labels and loaded values of members, until it returns false.
+// Like Range, it works on a snapshot of the Map, which is not locked during the calls.
+func (x *Map) ForEach(fn func(label string, value any) bool) {
+	for _, v := range x.all() {
+		loaded, ok := safeLoad(v)
+		if !ok {
+			continue
+		}
+		if !fn(v.Label, loaded) {
+			return
+		}
+	}
+}
+
+func (x *Map) Get(key any) (Value, bool) {
+	o, ok := x.load()[key]
+	return o, ok
+}
+
+// GetDelete removes a member and returns it, as a single step: among concurrent calls for the same key,
+// only one obtains the member. Returns false if the key is absent.
+func (x *Map) GetDelete(key any) (Value, bool) {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	o, ok := x.load()[key]
+	if !ok {
+		return Value{}, false
+	}
+	x.deletes.Add(1)
+	values := x.clone()
+	delete(values, key)
+	x.values.Store(&values)
+	delete(x.times, key)
+	return o, true
+}
+
+// LastSet returns the time the given key was last Set.
+// Returns false if the key is absent, or the Map doesn't track Set times.
+func (x *Map) LastSet(key any) (time.Time, bool) {
+	x.mux.Lock()
+	o, ok := x.times[key]
+	x.mux.Unlock()
+	return o, ok
+}
+
+// Ops returns the number of Set and Delete operations performed on the Map so far.
+// Members removed by DeleteStale count as deletes.
+func (x *Map) Ops() (sets, deletes uint64) {
+	return x.sets.Load(), x.deletes.Load()
+}
+
+// Range calls the given function with the labels and loaded values of all members.
+// Members whose Loader panics are skipped, as with all other loading methods; the panic is reported through the package Logger.
+// The Map is not locked during the calls; members Set or Deleted concurrently may or may not be visited.
+func (x *Map) Range(fn func(string, any)) {
+	for _, v := range x.all() {
+		if loaded, ok := safeLoad(v); ok {
+			fn(v.Label, loaded)
+		}
+	}
+}
+
+// RangeTagged is like Range, but also passes the Tags of each member, which tell apart the members of a family
+// sharing a label, such as those of a ValueVec.
+func (x *Map) RangeTagged(fn func(label string, tags map[string]string, value any)) {
+	for _, v := range x.all() {
+		if loaded, ok := safeLoad(v); ok {
+			fn(v.Label, v.Tags, loaded)
+		}
+	}
+}
+
+// Replace swaps the entire contents of the Map for the given members, in a single step:
+// concurrent readers see either the old or the new contents, never a mix.
+// The given map is copied, and may be reused afterwards.
+// Panics if the Map is strict and any label is invalid.
+func (x *Map) Replace(values map[any]Value) {
+	m := make(map[any]Value, len(values))
+	for k, v := range values {
+		x.check(v.Label)
+		m[k] = v
+	}
+
+	x.mux.Lock()
+	x.values.Store(&m)
+	if x.times != nil {
+		now := clockOr(x.Clock).Now()
+		x.times = make(map[any]time.Time, len(m))
+		for k := range m {
+			x.times[k] = now
+		}
+	}
+	x.reap()
+	x.mux.Unlock()
+}
+
+// RangeErr is like Range, but collects the errors returned by fn, along with those of failing Loaders,
+// instead of stopping at the first one.
+func (x *Map) RangeErr(fn func(label string, value any) error) []LabeledError {
+	var o []LabeledError
+	for _, v := range x.all() {
+		loaded, err := tryLoad(v)
+		if err == nil {
+			err = fn(v.Label, loaded)
+		}
+		if err != nil {
+			o = append(o, LabeledError{v.Label, err})
+		}
+	}
+	return o
+}
+
+// Set adds or replaces a member.
+// Panics if the Map is strict and the Value's label is invalid.
+func (x *Map) Set(key any, val Value) {
+	x.check(val.Label)
+	x.sets.Add(1)
+
+	x.mux.Lock()
+	values := x.clone()
+	values[key] = val
+	x.values.Store(&values)
+	if x.times != nil {
+		x.times[key] = clockOr(x.Clock).Now()
+	}
+	x.reap()
+	x.mux.Unlock()
+}
+
+// reap launches the expiry goroutine, if the Map expires members and it isn't running yet.
+// Must be called with the write lock held.
+func (x *Map) reap() {
+	r := x.reaper
+	if r == nil || r.started || r.closed {
+		return
+	}
+	r.started = true
+
+	ticker := clockOr(x.Clock).NewTicker(r.interval)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C():
+				x.DeleteStale(r.ttl)
+			}
+		}
+	}()
+}
+
+// SetNew is like Set, but only adds the member if the key is absent, returning false otherwise.
+// Among concurrent calls for the same absent key, only the first succeeds.
+func (x *Map) SetNew(key any, val Value) bool {
+	x.check(val.Label)
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if _, ok := x.load()[key]; ok {
+		return false
+	}
+	x.sets.Add(1)
+	values := x.clone()
+	values[key] = val
+	x.values.Store(&values)
+	if x.times != nil {
+		x.times[key] = clockOr(x.Clock).Now()
+	}
+	x.reap()
+	return true
+}
+
+// An Entry is a member of a Map with its value loaded.
+type Entry struct {
+	Key   any
+	Label string
+	Tags  map[string]string // of the member's Value, shared with it
+	Kind  string            // of the member's Loader, if Kinded
+	Value any
+}
+
+// Snapshot returns the members of the Map with their values loaded, sorted by label, then by tags.
+// The members are those of a single point in time, unaffected by concurrent Sets and Deletes;
+// the values are loaded one after the other, and are therefore only as consistent as their Loaders.
+// Members whose Loader panics are left out.
+func (x *Map) Snapshot() []Entry {
+	values := x.all()
+	o := make([]Entry, 0, len(values))
+	for k, v := range values {
+		if loaded, ok := safeLoad(v); ok {
+			e := Entry{Key: k, Label: v.Label, Tags: v.Tags, Value: loaded}
+			if kinded, ok := v.Loader.(Kinded); ok {
+				e.Kind = kinded.Kind()
+			}
+			o = append(o, e)
+		}
+	}
+
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Label != o[j].Label {
+			return o[i].Label < o[j].Label
+		}
+		return openMetricsLabels(o[i].Tags) < openMetricsLabels(o[j].Tags)
+	})
+	return o
+}
+
+// SnapshotJSON encodes a Snapshot as a JSON object keyed by label, as by taggedLabel, with bare values.
+// If several members share a label and tags, only one of them is encoded.
+// Values that can't be encoded, such as non-finite floats, are encoded as null.
+func (x *Map) SnapshotJSON() ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeJSON(&b, metrics(x), false); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Values returns all members of the Map, sorted by label, without loading them.
+func (x *Map) Values() []Value {
+	return metrics(x)
+}
+
+// labeled returns a Snapshot keyed by label, as by taggedLabel.
+// Among members sharing a key, the first one in Snapshot order is kept.
+func (x *Map) labeled() map[string]any {
+	entries := x.Snapshot()
+	o := make(map[string]any, len(entries))
+	for _, e := range entries {
+		key := taggedLabel(e.Label, e.Tags)
+		if _, ok := o[key]; !ok {
+			o[key] = e.Value
+		}
+	}
+	return o
+}
+
+// taggedLabel keys a member in exports keyed by label: the label itself, followed by the member's Tags
+// in OpenMetrics label syntax if it has any (e.g. `requests{code="200"}`), so that the members of a family stay apart.
+func taggedLabel(label string, tags map[string]string) string {
+	return label + openMetricsLabels(tags)
+}
+
+// parseTaggedLabel splits a key made by taggedLabel back into its label and tags.
+// Keys that don't end in a well formed label set are taken to be plain labels.
+func parseTaggedLabel(key string) (label string, tags map[string]string) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil
+	}
+
+	tags = make(map[string]string)
+	rest := key[start+1 : len(key)-1]
+	for rest != "" {
+		eq := strings.Index(rest, `="`)
+		if eq < 0 {
+			return key, nil
+		}
+		name := rest[:eq]
+
+		// find the closing quote, skipping escaped characters
+		var b strings.Builder
+		i := eq + 2
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				switch rest[i] {
+				case 'n':
+					b.WriteByte('\n')
+				default:
+					b.WriteByte(rest[i])
+				}
+				continue
+			}
+			b.WriteByte(rest[i])
+		}
+		if i == len(rest) {
+			return key, nil
+		}
+		tags[name] = b.String()
+
+		rest = rest[i+1:]
+		if rest != "" {
+			if rest[0] != ',' {
+				return key, nil
+			}
+			rest = rest[1:]
+		}
+	}
+	return key[:start], tags
+}
+
+// check panics if the Map is strict and the label is invalid.
+func (x *Map) check(label string) {
+	if x.validate == nil {
+		return
+	}
+	if err := x.validate(label); err != nil {
+		panic(fmt.Errorf("obs: invalid label: %w", err))
+	}
+}
+
+// clone returns a modifiable copy of the current contents.
+// Must be called with the write lock held.
+func (x *Map) clone() map[any]Value {
+	old := x.load()
+	o := make(map[any]Value, len(old)+1)
+	for k, v := range old {
+		o[k] = v
+	}
+	return o
+}
+
+// all returns the current contents along with those of all descendants, which must not be modified.
+// Members of children are keyed by childKey, and have their labels prefixed.
+func (x *Map) all() map[any]Value {
+	values := x.load()
+	children := x.children.Load()
+	if children == nil {
+		return values
+	}
+
+	o := make(map[any]Value, len(values))
+	for k, v := range values {
+		o[k] = v
+	}
+	for name, c := range *children {
+		for k, v := range c.all() {
+			v.Label = name + ChildSeparator + v.Label
+			o[childKey{name, k}] = v
+		}
+	}
+	return o
+}
+
+// A childKey identifies a member of a child Map within its parent.
+type childKey struct {
+	name string
+	key  any
+}
+
+// load returns the current contents, which must not be modified.
+func (x *Map) load() map[any]Value {
+	values := x.values.Load()
+	if values == nil {
+		// zero Map, never written to
+		return nil
+	}
+	return *values
+}
+
+// safeLoad loads a Value, recovering from a panicking Loader.
+// Returns false if the Loader panicked, after reporting it.
+func safeLoad(v Value) (any, bool) {
+	o, err := tryLoad(v)
+	if err != nil {
+		warn("obs: Loader panicked", "label", v.Label, "panic", err)
+		return nil, false
+	}
+	return o, true
+}
+
+// tryLoad loads a Value, converting a Loader panic into an error.
+func tryLoad(v Value) (o any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loader panicked: %v", r)
+		}
+	}()
+	return v.Load(), nil
+}
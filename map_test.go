@@ -0,0 +1,409 @@
+package obs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapZeroValue(t *testing.T) {
+	var m Map
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("zero Map: Get found a member")
+	}
+	m.Range(func(string, any) {
+		t.Fatal("zero Map: Range visited a member")
+	})
+
+	m.Set("a", Value{Label: "a", Loader: LoaderFunc[int](func() int { return 1 })})
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("zero Map: member not found after Set")
+	}
+}
+
+func TestMapConcurrentReads(t *testing.T) {
+	m := MapMake()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m.Set(j%10, Value{Label: fmt.Sprint(j % 10), Loader: LoaderFunc[int](func() int { return j })})
+				m.Delete((j + 5) % 10)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m.Get(j % 10)
+				m.Range(func(string, any) {})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// constant returns a Loader of v.
+func constant(v any) Loader {
+	return LoaderFunc[any](func() any { return v })
+}
+
+func TestMapOf(t *testing.T) {
+	m := MapOf(
+		Value{Label: "a", Loader: constant(1)},
+		Value{Label: "b", Loader: constant(2)},
+		Value{Label: "a", Loader: constant(3)},
+	)
+
+	got := make(map[string]any)
+	m.Range(func(label string, v any) {
+		got[label] = v
+	})
+	if len(got) != 2 || got["a"] != 3 || got["b"] != 2 {
+		t.Errorf("got %v, want the last of duplicate labels, and every other Value", got)
+	}
+
+	MapOf().Range(func(label string, _ any) {
+		t.Errorf("empty MapOf: visited %s", label)
+	})
+}
+
+func TestMapFilter(t *testing.T) {
+	m := MapOf(
+		Value{Label: "http_requests", Loader: constant(1)},
+		Value{Label: "http_errors", Loader: constant(2)},
+		Value{Label: "db_queries", Loader: constant(3)},
+	)
+
+	got := m.Filter(func(label string) bool { return strings.HasPrefix(label, "http_") })
+	if want := map[string]any{"http_requests": 1, "http_errors": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("by prefix: got %v, want %v", got, want)
+	}
+
+	got = m.Filter(func(label string) bool { return strings.Contains(label, "e") && !strings.HasSuffix(label, "s") })
+	if len(got) != 0 {
+		t.Errorf("matching none: got %v", got)
+	}
+
+	got = m.Filter(func(label string) bool { return len(label) < 11 })
+	if want := map[string]any{"db_queries": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("by length: got %v, want %v", got, want)
+	}
+}
+
+func TestMapForEach(t *testing.T) {
+	m := MapMake()
+	for i := 0; i < 10; i++ {
+		m.Set(i, Value{Label: fmt.Sprint(i), Loader: constant(i)})
+	}
+
+	var visited int
+	m.ForEach(func(string, any) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("visited %d members, want to stop at the third", visited)
+	}
+
+	visited = 0
+	m.ForEach(func(string, any) bool {
+		visited++
+		return true
+	})
+	if visited != 10 {
+		t.Errorf("visited %d members, want all 10", visited)
+	}
+}
+
+func TestMapPanickingLoader(t *testing.T) {
+	log := captureLog(t)
+	m := MapOf(
+		Value{Label: "bad", Loader: LoaderFunc[int](func() int { panic("boom") })},
+		Value{Label: "good", Loader: constant(1)},
+	)
+
+	var labels []string
+	m.Range(func(label string, _ any) {
+		labels = append(labels, label)
+	})
+	if len(labels) != 1 || labels[0] != "good" {
+		t.Errorf("Range visited %v, want only the good member", labels)
+	}
+
+	var b bytes.Buffer
+	if err := WritePrometheus(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "# TYPE good gauge\ngood 1\n" {
+		t.Errorf("export: got %q", got)
+	}
+	if !strings.Contains(log.String(), "Loader panicked") {
+		t.Errorf("panic not reported: logged %q", log)
+	}
+
+	// the Map is still usable
+	m.Set("other", Value{Label: "other", Loader: constant(2)})
+	m.Delete("bad")
+}
+
+func TestMapReplace(t *testing.T) {
+	// every generation holds the same number of members, all loading the generation number
+	generation := func(g int) map[any]Value {
+		values := make(map[any]Value)
+		for i := 0; i < 10; i++ {
+			values[i] = Value{Label: fmt.Sprint(i), Loader: constant(g)}
+		}
+		return values
+	}
+	m := MapMake()
+	m.Replace(generation(0))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for g := 1; g <= 200; g++ {
+			m.Replace(generation(g))
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		seen := make(map[any]int)
+		m.Range(func(_ string, v any) {
+			seen[v]++
+		})
+		if len(seen) != 1 {
+			t.Fatalf("Range mixed generations: %v", seen)
+		}
+		for _, n := range seen {
+			if n != 10 {
+				t.Fatalf("Range visited %d members, want 10", n)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+func TestMapRangeErr(t *testing.T) {
+	errOdd := errors.New("odd")
+	m := MapOf(
+		Value{Label: "a", Loader: constant(1)},
+		Value{Label: "b", Loader: constant(2)},
+		Value{Label: "c", Loader: constant(3)},
+		Value{Label: "panics", Loader: LoaderFunc[int](func() int { panic("boom") })},
+	)
+
+	var visited []string
+	errs := m.RangeErr(func(label string, v any) error {
+		visited = append(visited, label)
+		if v.(int)%2 == 1 {
+			return errOdd
+		}
+		return nil
+	})
+	sort.Strings(visited)
+	if len(visited) != 3 || visited[0] != "a" || visited[1] != "b" || visited[2] != "c" {
+		t.Errorf("visited %v, want every loadable member", visited)
+	}
+
+	failed := make(map[string]error)
+	for _, err := range errs {
+		failed[err.Label] = err.Err
+	}
+	if len(errs) != 3 || failed["a"] != errOdd || failed["c"] != errOdd || failed["panics"] == nil {
+		t.Errorf("got errors %v", errs)
+	}
+	if !errors.Is(errs[0], errs[0].Err) {
+		t.Error("LabeledError doesn't unwrap")
+	}
+}
+
+func TestMapOps(t *testing.T) {
+	m := MapMakeTimed()
+	for i := 0; i < 5; i++ {
+		m.Set(i, Value{Label: fmt.Sprint(i), Loader: constant(i)})
+	}
+	m.SetNew(0, Value{Label: "0", Loader: constant(0)}) // rejected
+	m.Delete(0)
+	m.Delete(1)
+	m.DeleteStale(-time.Hour)
+
+	if sets, deletes := m.Ops(); sets != 5 || deletes != 5 {
+		t.Errorf("got %d sets and %d deletes, want 5 and 5", sets, deletes)
+	}
+}
+
+func TestGetDelete(t *testing.T) {
+	m := MapMake()
+	for round := 0; round < 100; round++ {
+		m.Set("pending", Value{Label: "pending", Loader: constant(round)})
+
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if v, ok := m.GetDelete("pending"); ok {
+					wins.Add(1)
+					if got := v.Load(); got != round {
+						t.Errorf("round %d: got %v", round, got)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if n := wins.Load(); n != 1 {
+			t.Fatalf("round %d: %d goroutines got the member, want 1", round, n)
+		}
+		if _, ok := m.Get("pending"); ok {
+			t.Fatalf("round %d: member still present", round)
+		}
+	}
+	if _, ok := m.GetDelete("absent"); ok {
+		t.Error("absent key: got true")
+	}
+}
+
+func TestSetNew(t *testing.T) {
+	m := MapMake()
+	var wins atomic.Int32
+	var winner atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.SetNew("requests", Value{Label: "requests", Loader: constant(i)}) {
+				wins.Add(1)
+				winner.Store(int32(i))
+			}
+		}()
+	}
+	wg.Wait()
+	if n := wins.Load(); n != 1 {
+		t.Fatalf("%d SetNews succeeded, want 1", n)
+	}
+	// the winner's value was not clobbered by the losers
+	if v, _ := m.Get("requests"); v.Load() != int(winner.Load()) {
+		t.Errorf("got %v, want the winner's %d", v.Load(), winner.Load())
+	}
+
+	m.Delete("requests")
+	if !m.SetNew("requests", Value{Label: "requests", Loader: constant(-1)}) {
+		t.Error("after Delete: got false")
+	}
+}
+
+func TestSnapshotReentrant(t *testing.T) {
+	m := MapMake()
+	m.Set("b", Value{Label: "b", Loader: constant(2)})
+	m.Set("a", Value{Label: "a", Loader: LoaderFunc[int](func() int {
+		// Loaders may use the Map, which is not locked while they run
+		m.Set("c", Value{Label: "c", Loader: constant(3)})
+		return 1
+	})})
+	m.Set("broken", Value{Label: "broken", Loader: LoaderFunc[int](func() int { panic("boom") })})
+
+	entries := m.Snapshot()
+	if len(entries) != 2 || entries[0].Label != "a" || entries[0].Value != 1 || entries[1].Label != "b" {
+		t.Errorf("got %+v, want the members at the time of the call, without the panicking one", entries)
+	}
+	if _, ok := m.Get("c"); !ok {
+		t.Error("Set from a Loader was lost")
+	}
+
+	if vs := m.Values(); len(vs) != 4 || vs[0].Label != "a" || vs[3].Label != "c" {
+		t.Errorf("Values: got %d members", len(vs))
+	}
+}
+
+func TestChild(t *testing.T) {
+	root := MapMake()
+	root.Set("up", Value{Label: "up", Loader: constant(1)})
+	db := root.Child("db")
+	if root.Child("db") != db {
+		t.Error("Child returned a new Map for an existing name")
+	}
+	db.Set("q", Value{Label: "queries", Loader: constant(2)})
+	db.Child("pool").Set("open", Value{Label: "open", Loader: constant(3)})
+
+	var labels []string
+	for _, e := range root.Snapshot() {
+		labels = append(labels, e.Label)
+	}
+	if want := []string{"db.pool.open", "db.queries", "up"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+	if _, ok := root.Get("q"); ok {
+		t.Error("child member accessible by key through the parent")
+	}
+
+	// removing from a child shows in the parent
+	db.Delete("q")
+	if n := len(root.Snapshot()); n != 2 {
+		t.Errorf("after Delete: got %d members, want 2", n)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("child of a strict Map accepted an invalid label")
+		}
+	}()
+	MapMakeStrict(PrometheusValid).Child("c").Set("x", Value{Label: "not valid", Loader: constant(0)})
+}
+
+// benchMap returns a Map of n members.
+func benchMap(n int) *Map {
+	m := MapMake()
+	for i := 0; i < n; i++ {
+		m.Set(i, Value{Label: fmt.Sprint("v", i), Loader: &Counter{}})
+	}
+	return m
+}
+
+func BenchmarkMapGet(b *testing.B) {
+	m := benchMap(100)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Get(i % 100)
+			i++
+		}
+	})
+}
+
+func BenchmarkMapRange(b *testing.B) {
+	m := benchMap(100)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.Range(func(string, any) {})
+		}
+	})
+}
+
+// BenchmarkMapRangeMutex is the locked read path that copy-on-write replaced, for comparison.
+func BenchmarkMapRangeMutex(b *testing.B) {
+	values := benchMap(100).load()
+	var mux sync.RWMutex
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mux.RLock()
+			for _, v := range values {
+				safeLoad(v)
+			}
+			mux.RUnlock()
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package obs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalJSON encodes the loaded members of the Map as by StreamJSON, with Kinded members as
+// {"type": kind, "value": value} objects. Use SnapshotJSON for bare values.
+func (x *Map) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	if err := streamJSON(&b, metrics(x)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MarshalText encodes the loaded members of the Map on a single line of space separated label=value pairs,
+// sorted by label, for structured logs. Tagged members are labeled as in StreamJSON.
+// Labels and values containing spaces, quotes or equal signs are quoted.
+// Members whose Loader panics are left out, as are all but one of the members sharing a label and tags.
+func (x *Map) MarshalText() ([]byte, error) {
+	return marshalText(metrics(x), false)
+}
+
+// WithKinds returns a view of the Map whose text encoding includes the kind of Kinded members, as its JSON encoding does.
+func (x *Map) WithKinds() KindedMap {
+	return KindedMap{x}
+}
+
+// A KindedMap encodes a Map along with the kind of its Kinded members.
+type KindedMap struct {
+	m *Map
+}
+
+// MarshalJSON is the same as the Map's MarshalJSON.
+func (x KindedMap) MarshalJSON() ([]byte, error) {
+	return x.m.MarshalJSON()
+}
+
+// MarshalText is like Map.MarshalText, but writes the pairs of Kinded members as label:kind=value.
+func (x KindedMap) MarshalText() ([]byte, error) {
+	return marshalText(metrics(x.m), true)
+}
+
+func marshalText(values []Value, kinds bool) ([]byte, error) {
+	keys, values := taggedLabels(values)
+
+	var b bytes.Buffer
+	last := ""
+	for i, v := range values {
+		if i > 0 && keys[i] == last {
+			continue
+		}
+		last = keys[i]
+
+		loaded, ok := safeLoad(v)
+		if !ok {
+			continue
+		}
+		text, err := textOf(loaded)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(quoteText(keys[i]))
+		if k, ok := v.Loader.(Kinded); ok && kinds {
+			b.WriteString(":" + k.Kind())
+		}
+		b.WriteString("=" + quoteText(text))
+	}
+	return b.Bytes(), nil
+}
+
+// textOf formats a loaded value for text encodings.
+// Values that are neither text marshalers, strings nor basic types are encoded as JSON.
+func textOf(v any) (string, error) {
+	switch v := v.(type) {
+	case encoding.TextMarshaler:
+		data, err := v.MarshalText()
+		return string(data), err
+	case string:
+		return v, nil
+	case fmt.Stringer:
+		return v.String(), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		return fmt.Sprint(v), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// quoteText quotes s if it wouldn't otherwise be read back as a single label or value.
+func quoteText(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// MarshalJSON encodes the Value as a self-describing JSON object: its label, its Kind if Kinded,
+// its loaded value, and its unit, help and tags if set.
+// Returns an error if the Loader panics.
+func (x Value) MarshalJSON() ([]byte, error) {
+	loaded, err := tryLoad(x)
+	if err != nil {
+		return nil, err
+	}
+
+	o := valueJSON{
+		Label: x.Label,
+		Value: loaded,
+		Unit:  x.Unit,
+		Help:  x.Help,
+		Tags:  x.Tags,
+	}
+	if k, ok := x.Loader.(Kinded); ok {
+		o.Type = k.Kind()
+	}
+	return json.Marshal(o)
+}
+
+// MarshalText encodes the Value as a label=value pair, as found in the text encoding of a Map.
+// Returns an error if the Loader panics.
+func (x Value) MarshalText() ([]byte, error) {
+	loaded, err := tryLoad(x)
+	if err != nil {
+		return nil, err
+	}
+	text, err := textOf(loaded)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(quoteText(x.Label) + "=" + quoteText(text)), nil
+}
+
+type valueJSON struct {
+	Label string            `json:"label"`
+	Type  string            `json:"type,omitempty"`
+	Value any               `json:"value"`
+	Unit  string            `json:"unit,omitempty"`
+	Help  string            `json:"help,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
@@ -0,0 +1,68 @@
+package obs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMarshalMap(t *testing.T) {
+	var c Counter
+	c.Add(2)
+	m := MapOf(
+		Value{Label: "requests", Loader: &c},
+		Value{Label: "status", Loader: constant("all good")},
+		Value{Label: "uptime", Loader: constant(90 * time.Second)},
+		Value{Label: "sizes", Loader: constant([]int{1, 2})},
+	)
+
+	text, err := m.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(text), `requests=2 sizes=[1,2] status="all good" uptime=1m30s`; got != want {
+		t.Errorf("text: got %s, want %s", got, want)
+	}
+	text, _ = m.WithKinds().MarshalText()
+	if got, want := string(text), `requests:counter=2 sizes=[1,2] status="all good" uptime=1m30s`; got != want {
+		t.Errorf("kinded text: got %s, want %s", got, want)
+	}
+
+	// deterministic, and usable by encoding/json directly
+	data, err := json.Marshal(map[string]any{"metrics": m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := json.Marshal(map[string]any{"metrics": m.WithKinds()})
+	if got, want := string(data), `{"metrics":{"requests":{"type":"counter","value":2},"sizes":[1,2],"status":"all good","uptime":90000000000}}`; got != want {
+		t.Errorf("JSON: got %s, want %s", got, want)
+	}
+	if string(again) != string(data) {
+		t.Errorf("kinded JSON: got %s", again)
+	}
+}
+
+func TestMarshalValue(t *testing.T) {
+	var c Counter
+	c.Add(5)
+	v := Value{Label: "requests", Loader: &c, Unit: "requests", Help: "served", Tags: map[string]string{"code": "200"}}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"label":"requests","type":"counter","value":5,"unit":"requests","help":"served","tags":{"code":"200"}}`; got != want {
+		t.Errorf("JSON: got %s, want %s", got, want)
+	}
+	text, _ := v.MarshalText()
+	if got := string(text); got != "requests=5" {
+		t.Errorf("text: got %s", got)
+	}
+
+	broken := Value{Label: "broken", Loader: LoaderFunc[int](func() int { panic("boom") })}
+	if _, err := json.Marshal(broken); err == nil {
+		t.Error("panicking Loader: got nil error")
+	}
+	if _, err := broken.MarshalText(); err == nil {
+		t.Error("panicking Loader: got nil text error")
+	}
+}
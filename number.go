@@ -0,0 +1,36 @@
+package obs
+
+// Number is satisfied by all integer and floating point types.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Sum is a sample function that adds samples to the state.
+func Sum[T Number](s *T, v T) {
+	*s += v
+}
+
+// Last is a sample function that sets the state to the latest sample.
+func Last[T any](s *T, v T) {
+	*s = v
+}
+
+// Extremes are the smallest and largest of a sequence of numbers.
+// Add is a (non allocating) sample function, for use with SamplerMake.
+type Extremes[T Number] struct {
+	Min T
+	Max T
+	N   int // number of values seen; Min and Max are meaningless while it is 0
+}
+
+func (x *Extremes[T]) Add(v T) {
+	if x.N == 0 || v < x.Min {
+		x.Min = v
+	}
+	if x.N == 0 || v > x.Max {
+		x.Max = v
+	}
+	x.N++
+}
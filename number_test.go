@@ -0,0 +1,67 @@
+package obs
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	var s int64
+	for _, v := range []int64{1, 2, 3} {
+		Sum(&s, v)
+	}
+	if s != 6 {
+		t.Errorf("got %v, want 6", s)
+	}
+}
+
+func TestExtremes(t *testing.T) {
+	var x Extremes[float64]
+	for _, v := range []float64{3, -1, 2} {
+		x.Add(v)
+	}
+	if x.Min != -1 || x.Max != 3 || x.N != 3 {
+		t.Errorf("got %+v", x)
+	}
+}
+
+func TestAggregationAllocs(t *testing.T) {
+	var i int64
+	var f Extremes[float64]
+	n := testing.AllocsPerRun(100, func() {
+		Sum(&i, 1)
+		f.Add(1.5)
+	})
+	if n != 0 {
+		t.Errorf("aggregation allocated %v times per run", n)
+	}
+}
+
+func BenchmarkSumInt64(b *testing.B) {
+	b.ReportAllocs()
+	var s int64
+	for i := 0; i < b.N; i++ {
+		Sum(&s, int64(i))
+	}
+}
+
+func BenchmarkSumFloat64(b *testing.B) {
+	b.ReportAllocs()
+	var s float64
+	for i := 0; i < b.N; i++ {
+		Sum(&s, float64(i))
+	}
+}
+
+func BenchmarkExtremesInt64(b *testing.B) {
+	b.ReportAllocs()
+	var x Extremes[int64]
+	for i := 0; i < b.N; i++ {
+		x.Add(int64(i))
+	}
+}
+
+func BenchmarkExtremesFloat64(b *testing.B) {
+	b.ReportAllocs()
+	var x Extremes[float64]
+	for i := 0; i < b.N; i++ {
+		x.Add(float64(i))
+	}
+}
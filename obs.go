@@ -1,137 +1,1485 @@
 // Package obs provides code instrumentation.
 package obs
 
-import "sync"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrFormat     = errors.New("unknown export format")
+	ErrGated      = errors.New("sampler gated")
+	ErrInactive   = errors.New("sampler inactive")
+	ErrLimit      = errors.New("active sampler limit reached")
+	ErrNotStarted = errors.New("sampler not started")
+	ErrOverloaded = errors.New("sampler overloaded")
+	ErrQuota      = errors.New("sample quota exhausted")
+	ErrStarted    = errors.New("sampler already started")
+	ErrTimeout    = errors.New("timeout")
+)
 
 // A Loader can safely obtain values for inspection.
 type Loader interface {
 	Load() any
 }
 
-// A Map groups and provides access to a set of Values.
+// A Sampler accepts samples in a finite queue, and processes them in a dedicated goroutine.
+// If the sample queue would overflow, emits a warning and discards all subsequent samples.
+//
+// Samples pushed before Start are discarded, unless Buffer is set, in which case they are queued
+// (as long as there is room) and processed once the Sampler is started.
 //
-// Its methods are concurrent safe.
-type Map struct {
-	values map[any]Value
-	mux    sync.Mutex
+// Samples may be pushed from any number of goroutines, concurrently with Start and Stop.
+// Samples pushed once Stop has been called are discarded.
+//
+// The lifecycle callbacks run in order: OnStart, First, (samples are processed), OnStop, (the queue is drained),
+// the partial OnWindow, Final.
+type Sampler[S any, T any] struct {
+	Final func(*S)    // called when the last sample has been processed, if non-nil
+	First func(*S, T) // called on the first sample, before the normal sampling function, if non-nil
+
+	// Overflow, if non-nil, is called when a queue overflow occurs. It receives no count: the samples discarded
+	// because of the overflow are counted from then on in the Dropped field of Stats, as also returned by Dropped.
+	Overflow func()
+
+	// OnOverflow, if non-nil, is consulted before an overflow shuts the Sampler down.
+	// It may request a larger queue by returning its new size and true, in which case the queue is replaced
+	// (keeping all queued samples) and sampling continues. Sizes above MaxQueueSize are refused.
+	// Only applies in the default overflow mode, not with a Strategy, FoldOnOverflow or watermarks.
+	OnOverflow func(SamplerStats) (newSize int, ok bool)
+
+	// OnError, if non-nil, is called by the processing goroutine, while holding the state lock, with each sample
+	// a fallible sample function (see SamplerMakeErr) failed to process. Returning true shuts the Sampler down
+	// as by Stop, in which case samples that are already queued are still processed.
+	OnError func(v T, err error) (stop bool)
+
+	OnStart func(*S) // called by the processing goroutine before it waits for the first sample, if non-nil
+	OnStop  func(*S) // called by the first Stop call on a started Sampler, before the queue is drained, if non-nil
+
+	// Strategy, if non-nil, decides what happens to samples that find the queue full, superseding
+	// the default overflow mode, FoldOnOverflow and watermarks.
+	Strategy OverflowStrategy
+
+	// FoldOnOverflow, if non-nil, replaces the overflow shutdown: samples that don't fit in the queue are folded
+	// directly into the state, in the producer's goroutine, under the same lock the processing goroutine uses.
+	// Samples are then no longer processed in order, so this only suits order insensitive aggregations (e.g. sums).
+	FoldOnOverflow func(*S, T)
+
+	// Clone, if non-nil, makes a defensive copy of every sample before it is queued (and before Transform).
+	// Without it, callers must not modify memory referenced by a sample (e.g. a reused slice) after pushing it,
+	// as the processing goroutine may not have gotten to it yet.
+	Clone func(T) T
+
+	// Sampling, if non-nil, selects the samples to accept, such as EveryNth, Probability or a RateLimit.
+	// It is applied by the producer after the gate, so the rest are discarded before any queuing cost,
+	// and counted by Unsampled. Samples of a batch are selected individually. Must be set before Start.
+	Sampling SamplingPolicy
+
+	// Transform, if non-nil, is applied to every sample before it is queued.
+	// It runs in the producer's goroutine, so it adds to the cost of every Sample call.
+	Transform func(T) T
+
+	// OnHighWater, if non-nil, is called by the processing goroutine when the queue depth reaches the HighWater
+	// fraction of its capacity, as an early warning before an overflow. It fires once per crossing,
+	// and is rearmed when the depth falls back below the threshold, or down to the LowWater fraction if it is set,
+	// at which point OnLowWater is called, if non-nil, to signal that the pressure has eased.
+	HighWater   float64
+	OnHighWater func(depth, cap int)
+	LowWater    float64
+	OnLowWater  func(depth, cap int)
+
+	// AutoGrow makes high water crossings (see HighWater) double the queue size, up to MaxQueueSize,
+	// keeping all queued samples, so that bursts are absorbed before they can overflow it.
+	AutoGrow bool
+
+	Buffer      bool // queue samples pushed before Start
+	KeepDropped int  // number of most recent samples discarded after an overflow to retain for inspection
+	KeepHistory int  // number of most recently processed samples to retain for inspection
+
+	Log *SampleLog[T] // records processed samples, if non-nil
+
+	// Quota, if non-nil, is shared with other Samplers to bound their combined queued samples.
+	// Samples that find it exhausted are dropped, regardless of room in the queue. Must be set before Start.
+	Quota *Quota
+
+	// MeasureWaits enables timing (using Clock) of sends that block on a full queue, exposed by EnqueueWaits.
+	// Only applies to the default overflow mode, the only one in which producers block.
+	MeasureWaits bool
+
+	MeasureProcessing bool // enables timing (using Clock) of sample function calls, exposed by ProcessingTime
+
+	// OnWindow, if non-nil, is called every Window interval with the state aggregated during it, which is then reset
+	// as by SnapshotReset. When the Sampler stops, the window in progress is flushed after the queue is drained,
+	// before Final, flagged as partial. That last window is not reset, so Final and StopAndCollect still see it.
+	Window   time.Duration
+	OnWindow func(state S, partial bool)
+	Clock    Clock // time source for windows and wait measurements; the real clock if nil
+
+	// OnStall, if non-nil, is called by the processing goroutine when no sample has arrived for StallTimeout,
+	// with the time of the last one (or of Start). It fires once per stall, and is rearmed by the next sample.
+	// Stalls are checked every half StallTimeout, using Clock.
+	StallTimeout time.Duration
+	OnStall      func(since time.Time)
+
+	// OnTick, if non-nil, is called by the processing goroutine every TickInterval, between samples,
+	// even if none arrive, for periodic work on the state such as flushing or rotation. Uses Clock.
+	TickInterval time.Duration
+	OnTick       func(*S)
+
+	// DebugAssertOrdered makes the processing goroutine panic if a sample's Sequence is lower than the previous one's,
+	// for catching ordering violations in tests. Has no effect without a Sequence function.
+	DebugAssertOrdered bool
+	Sequence           func(T) uint64
+
+	Timestamp    func(T) time.Time         // extracts sample timestamps, enabling out-of-order detection, if non-nil
+	OnOutOfOrder func(prev, cur time.Time) // called when a sample's timestamp precedes the previous one's, if non-nil
+
+	state    *S
+	stateMux sync.Mutex // held by the processing goroutine while it works on the state
+
+	sampleChan atomic.Pointer[chan item[T]] // only replaced or closed while holding queueMux
+	queueMux   sync.RWMutex                 // read locked by producers while they send
+	sampleFunc atomic.Pointer[func(*S, T)]
+
+	// successors links replaced queues to their replacements, for the processing goroutine to follow.
+	// Separately locked, as producers may hold queueMux while blocked on the processing goroutine.
+	successors    map[chan item[T]]chan item[T]
+	successorsMux sync.Mutex
+
+	equal   func(T, T) bool // non-nil in coalescing mode
+	runFunc atomic.Pointer[func(*S, T, int)]
+
+	batchFunc atomic.Pointer[func(*S, []T)] // nil value if batches are processed one sample at a time
+
+	shards     []shard[T] // nil unless sharded
+	shardBatch int
+	shardFlush time.Duration
+	shardNext  atomic.Uint64
+
+	checkpointInterval time.Duration // 0 unless checkpointing
+	checkpointOpen     func() (io.WriteCloser, error)
+
+	// Lifecycle flags, read by producers without locking. closed and started only change while holding queueMux,
+	// so a producer that checks closed under its read lock can safely send.
+	inactive   atomic.Bool
+	started    atomic.Bool
+	closed     atomic.Bool
+	overflowed atomic.Bool
+	stop       atomic.Bool // set by the processing goroutine when the queue has filled up
+
+	closeOnce sync.Once
+	stopOnce  sync.Once
+	done      chan struct{} // closed when the processing goroutine exits
+
+	aboveHighWater bool // only used by the processing goroutine
+	growing        atomic.Bool
+
+	lastSeq    uint64    // sequence of the last processed sample
+	lastTime   time.Time // timestamp of the last processed sample
+	outOfOrder atomic.Uint64
+
+	waits      durations // of blocked sends, if MeasureWaits is set
+	processing durations // of sample function calls, if MeasureProcessing is set
+
+	dropped    ring[T]
+	droppedMux sync.Mutex
+	history    ring[T]
+	historyMux sync.Mutex
+
+	failed atomic.Uint64
+
+	gateClosed atomic.Bool
+	gated      atomic.Uint64
+	unsampled  atomic.Uint64
+
+	highMark   int // queue depth entering overload; 0 if the Sampler shuts down on overflow instead
+	lowMark    int // queue depth leaving overload
+	overloaded atomic.Bool
+	drops      atomic.Uint64
+
+	downsampleHigh float64 // queue fraction above which samples are skipped
+	maxSkip        int     // 0 if not downsampling
+	skipPos        int     // samples taken since the last processed one; only used by the processing goroutine
+	skipFactor     atomic.Int64
+	skipped        atomic.Uint64
 }
 
-func MapMake() *Map {
-	return &Map{
-		values: make(map[any]Value),
-	}
+func SamplerMake[S any, T any](queueSize int, sampleFunc func(*S, T)) *Sampler[S, T] {
+	return SamplerMakeState(queueSize, new(S), sampleFunc)
 }
 
-func (x *Map) Delete(key any) {
-	x.mux.Lock()
-	delete(x.values, key)
-	x.mux.Unlock()
+// An AnySampler is a type-erased view of a Sampler, satisfied by all Sampler instantiations.
+type AnySampler interface {
+	Loader
+	Start()
+	Stop()
+}
+
+// Load returns a snapshot of the Sampler's state.
+func (x *Sampler[S, T]) Load() any {
+	return Snapshot(x)
 }
 
-func (x *Map) Get(key any) (Value, bool) {
-	x.mux.Lock()
-	o, ok := x.values[key]
-	x.mux.Unlock()
-	return o, ok
+// Sample is the method form of the Sample function, allowing Samplers to satisfy interfaces.
+func (x *Sampler[S, T]) Sample(v T) {
+	Sample(x, v)
 }
 
-// Range calls the given function with the labels and loaded values of all members.
-func (x *Map) Range(fn func(string, any)) {
-	x.mux.Lock()
-	for _, v := range x.values {
-		fn(v.Label, v.Load())
+// SnapshotReset is the method form of the SnapshotReset function, returning an S.
+func (x *Sampler[S, T]) SnapshotReset() any {
+	return SnapshotReset(x)
+}
+
+// Start is the method form of the Start function.
+func (x *Sampler[S, T]) Start() {
+	Start(x)
+}
+
+// Done returns a channel that is closed once the Sampler has been stopped, and has finished processing and returned from Final.
+func (x *Sampler[S, T]) Done() <-chan struct{} {
+	return x.done
+}
+
+// Stop is the method form of the Stop function.
+func (x *Sampler[S, T]) Stop() {
+	Stop(x)
+}
+
+// SamplerMakeState returns a Sampler that aggregates into the given state, instead of an internal one.
+//
+// Only the processing goroutine writes to the state while the Sampler is running.
+// Reading it concurrently from elsewhere must be synchronized by the caller (for example by a lock taken in sampleFunc).
+func SamplerMakeState[S any, T any](queueSize int, state *S, sampleFunc func(*S, T)) *Sampler[S, T] {
+	x := &Sampler[S, T]{
+		state: state,
+		done:  make(chan struct{}),
 	}
-	x.mux.Unlock()
+	x.inactive.Store(true)
+	ch := make(chan item[T], queueSize)
+	x.sampleChan.Store(&ch)
+	x.sampleFunc.Store(&sampleFunc)
+	return x
 }
 
-func (x *Map) Set(key any, val Value) {
-	x.mux.Lock()
-	x.values[key] = val
-	x.mux.Unlock()
+// SamplerMakeCoalesce returns a Sampler that collapses runs of consecutive equal samples,
+// processing each run once along with its length.
+//
+// Only samples that are already queued are collapsed; a pending run is processed as soon as the queue runs empty.
+func SamplerMakeCoalesce[S any, T any](queueSize int, equal func(T, T) bool, sampleFunc func(*S, T, int)) *Sampler[S, T] {
+	x := SamplerMake(queueSize, func(s *S, v T) {
+		sampleFunc(s, v, 1)
+	})
+	x.equal = equal
+	x.runFunc.Store(&sampleFunc)
+	return x
 }
 
-// A Sampler accepts samples in a finite queue, and processes them in a dedicated goroutine.
-// If the sample queue would overflow, emits a warning and discards all subsequent samples.
-type Sampler[S any, T any] struct {
-	Final    func(*S)    // called when the last sample has been processed, if non-nil
-	First    func(*S, T) // called on the first sample, before the normal sampling function, if non-nil
-	Overflow func()      // called when a queue overflow occurs, if non-nil
+// SetFunc replaces the Sampler's sample function, starting with the next processed sample.
+// Safe to use while the Sampler is running.
+//
+// In coalescing mode, the new function is called once for every sample in a run.
+func SetFunc[S any, T any](x *Sampler[S, T], sampleFunc func(*S, T)) {
+	x.sampleFunc.Store(&sampleFunc)
+	if x.equal != nil {
+		runFunc := func(s *S, v T, n int) {
+			for i := 0; i < n; i++ {
+				sampleFunc(s, v)
+			}
+		}
+		x.runFunc.Store(&runFunc)
+	}
+}
 
-	state S
+// SamplerMakeErr is like SamplerMake, but with a sample function that may fail.
+// Failures are counted, and passed to OnError.
+func SamplerMakeErr[S any, T any](queueSize int, sampleFunc func(*S, T) error) *Sampler[S, T] {
+	x := SamplerMake[S, T](queueSize, nil)
+	SetFuncErr(x, sampleFunc)
+	return x
+}
 
-	sampleChan chan T
-	sampleFunc func(*S, T)
+// SetFuncErr is like SetFunc, but with a sample function that may fail, as for SamplerMakeErr.
+func SetFuncErr[S any, T any](x *Sampler[S, T], sampleFunc func(*S, T) error) {
+	SetFunc(x, func(s *S, v T) {
+		if err := sampleFunc(s, v); err != nil {
+			fail(x, v, err)
+		}
+	})
+}
 
-	inactive bool
-	stop     bool
+// Failed returns the number of samples a fallible sample function failed to process.
+func Failed[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.failed.Load()
 }
 
-func SamplerMake[S any, T any](queueSize int, sampleFunc func(*S, T)) *Sampler[S, T] {
-	return &Sampler[S, T]{
-		sampleChan: make(chan T, queueSize),
-		sampleFunc: sampleFunc,
-		inactive:   true,
+func fail[S any, T any](x *Sampler[S, T], v T, err error) {
+	x.failed.Add(1)
+	if x.OnError != nil && x.OnError(v, err) {
+		// Stop may have to wait for producers, which in turn may be waiting for this goroutine
+		go Stop(x)
 	}
 }
 
+// SetBatchFunc sets a function processing whole batches pushed by SampleBatch, in place of calling the sample function
+// for each of their samples. Samples pushed individually are still processed by the sample function.
+// A nil function restores the default. Safe to use while the Sampler is running.
+//
+// Has no effect in coalescing mode, where batches are split into runs.
+func SetBatchFunc[S any, T any](x *Sampler[S, T], batchFunc func(*S, []T)) {
+	if batchFunc == nil {
+		x.batchFunc.Store(nil)
+		return
+	}
+	x.batchFunc.Store(&batchFunc)
+}
+
 // Sample pushes a new sample for the Sampler to process.
-// NoOp if the Sampler is inactive (closed or has overflowed).
+// NoOp if the Sampler is inactive (not started, closed or has overflowed).
 func Sample[S any, T any](x *Sampler[S, T], v T) {
-	if x.inactive {
-		return
+	SampleErr(x, v)
+}
+
+// SampleErr is like Sample, but reports discarded samples.
+// Samples rejected by the SamplingPolicy are not reported, as their discard is intended.
+// Returns ErrGated if the Sampler's gate is closed, ErrNotStarted if the Sampler has not been started yet
+// (and the sample could not be buffered), ErrOverloaded if it is recovering from an overflow or its Strategy dropped
+// the sample, ErrQuota if its Quota is exhausted, or ErrInactive if it has been closed or has overflowed.
+func SampleErr[S any, T any](x *Sampler[S, T], v T) error {
+	return push(x, item[T]{v: v})
+}
+
+// SampleBatch pushes several samples at once, taking up a single queue slot (and a single unit of Quota),
+// which spares high frequency producers the cost of a channel operation per sample.
+// The slice is copied, and may be reused afterwards.
+// The samples are processed in order, under a single lock, by the batch function if one is set.
+// Reports discards like SampleErr; the batch is accepted or discarded as a whole. NoOp for empty batches.
+func SampleBatch[S any, T any](x *Sampler[S, T], vs []T) error {
+	if len(vs) == 0 {
+		return nil
+	}
+	return push(x, item[T]{batch: append([]T(nil), vs...)})
+}
+
+// SampleAck is like Sample, but returns a channel that is closed once the sample has been processed.
+// If the sample is discarded, the returned channel is already closed; use SampleErr to find out about discards.
+//
+// Every acknowledged sample costs a channel allocation, so this is meant for occasional use.
+func SampleAck[S any, T any](x *Sampler[S, T], v T) <-chan struct{} {
+	ack := make(chan struct{})
+	push(x, item[T]{v: v, ack: ack})
+	return ack
+}
+
+// Flush blocks until every sample pushed before the call has been processed (including those buffered by shards,
+// and a pending coalesced run), for synchronizing with the processing goroutine without stopping it,
+// for example in tests or at checkpoint boundaries. Samples that were discarded are not waited for.
+// Returns ErrNotStarted if the Sampler has not been started yet, or ErrInactive if it has been closed or has overflowed.
+func Flush[S any, T any](x *Sampler[S, T]) error {
+	if !x.started.Load() {
+		return ErrNotStarted
+	}
+
+	ack := make(chan struct{})
+	if !send(x, item[T]{flush: true, ack: ack}) {
+		return ErrInactive
+	}
+	<-ack
+	return nil
+}
+
+func push[S any, T any](x *Sampler[S, T], it item[T]) error {
+	if x.gateClosed.Load() {
+		x.gated.Add(1)
+		it.done()
+		return ErrGated
 	}
 
-	x.sampleChan <- v
-	if x.stop {
-		x.inactive = true
-		close(x.sampleChan)
+	if x.Sampling != nil && !sample(x, &it) {
+		it.done()
+		return nil
+	}
+
+	if it.batch != nil {
+		for i := range it.batch {
+			if x.Clone != nil {
+				it.batch[i] = x.Clone(it.batch[i])
+			}
+			if x.Transform != nil {
+				it.batch[i] = x.Transform(it.batch[i])
+			}
+		}
+	} else {
+		if x.Clone != nil {
+			it.v = x.Clone(it.v)
+		}
+		if x.Transform != nil {
+			it.v = x.Transform(it.v)
+		}
+	}
+
+	if x.shards != nil && !x.inactive.Load() && it.batch == nil && it.ack == nil {
+		return pushShard(x, it)
+	}
+	return enqueue(x, it)
+}
+
+// enqueue is the queuing half of push, following the producer side processing of the sample.
+func enqueue[S any, T any](x *Sampler[S, T], it item[T]) error {
+	if x.Quota != nil {
+		if !x.Quota.acquire() {
+			dropItem(x, it)
+			it.done()
+			return ErrQuota
+		}
+		it.quota = x.Quota
+	}
 
-		if x.Overflow != nil {
+	if x.inactive.Load() {
+		if x.overflowed.Load() || it.shard {
+			dropItem(x, it)
+		}
+		if x.started.Load() || x.closed.Load() {
+			it.done()
+			return ErrInactive
+		}
+
+		if x.Buffer && trySend(x, it) {
+			return nil
+		}
+		it.done()
+		return ErrNotStarted
+	}
+
+	if x.Strategy != nil {
+		return pushStrategy(x, it)
+	}
+
+	if x.FoldOnOverflow != nil {
+		if !trySend(x, it) {
+			x.stateMux.Lock()
+			if it.batch != nil {
+				for _, v := range it.batch {
+					x.FoldOnOverflow(x.state, v)
+				}
+			} else {
+				x.FoldOnOverflow(x.state, it.v)
+			}
+			x.stateMux.Unlock()
+			it.done()
+		}
+		return nil
+	}
+
+	if x.highMark > 0 {
+		if !x.overloaded.Load() {
+			if trySend(x, it) {
+				return nil
+			}
+			overload(x)
+		}
+		dropItem(x, it)
+		it.done()
+		return ErrOverloaded
+	}
+
+	if !send(x, it) {
+		return reject(x, it)
+	}
+	if x.stop.Load() {
+		if x.OnOverflow != nil && grow(x) {
+			return nil
+		}
+
+		x.overflowed.Store(true)
+		if deactivate(x) && x.Overflow != nil {
 			x.Overflow()
 		}
 	}
+	return nil
+}
+
+// reject refuses an item that arrived as the Sampler was closed, returning ErrInactive.
+// Shard buffers are counted as dropped, as nobody else will report their loss.
+func reject[S any, T any](x *Sampler[S, T], it item[T]) error {
+	if it.shard {
+		dropItem(x, it)
+	}
+	it.done()
+	return ErrInactive
+}
+
+// send queues an item, blocking while the queue is full.
+// Returns false if the queue has been closed.
+func send[S any, T any](x *Sampler[S, T], it item[T]) bool {
+	x.queueMux.RLock()
+	defer x.queueMux.RUnlock()
+
+	if x.closed.Load() {
+		return false
+	}
+	ch := *x.sampleChan.Load()
+	if !x.MeasureWaits {
+		ch <- it
+		return true
+	}
+
+	select {
+	case ch <- it:
+		return true
+	default:
+	}
+	clock := clockOr(x.Clock)
+	start := clock.Now()
+	ch <- it
+	x.waits.add(clock.Now().Sub(start))
+	return true
+}
+
+// EnqueueWaits returns a Loader of the histogram of time producers spent blocked on a full queue, as a LatencyState
+// with FineLatencyBuckets. Empty unless MeasureWaits is set.
+func EnqueueWaits[S any, T any](x *Sampler[S, T]) Loader {
+	return &x.waits
+}
+
+// ProcessingTime returns a Loader of the histogram of time spent in the sample function per call, as a LatencyState
+// with FineLatencyBuckets. In coalescing mode, each call processes a whole run. Empty unless MeasureProcessing is set.
+func ProcessingTime[S any, T any](x *Sampler[S, T]) Loader {
+	return &x.processing
+}
+
+// trySend queues an item, if there is room for it.
+func trySend[S any, T any](x *Sampler[S, T], it item[T]) bool {
+	x.queueMux.RLock()
+	defer x.queueMux.RUnlock()
+
+	if x.closed.Load() {
+		return false
+	}
+	select {
+	case *x.sampleChan.Load() <- it:
+		return true
+	default:
+		return false
+	}
+}
+
+// grow consults OnOverflow about replacing a full queue with a larger one.
+// Returns false if the Sampler should shut down instead.
+func grow[S any, T any](x *Sampler[S, T]) bool {
+	x.queueMux.Lock()
+	defer x.queueMux.Unlock()
+
+	if x.closed.Load() {
+		return false
+	}
+	if !x.stop.Load() {
+		// another producer got here first
+		return true
+	}
+
+	size, ok := x.OnOverflow(stats(x))
+	if !ok || !replaceQueue(x, size) {
+		return false
+	}
+	x.stop.Store(false)
+	return true
+}
+
+// growTo replaces the queue with a larger one of the given size.
+// Returns false if the Sampler has been closed, or the size is not an acceptable increase.
+func growTo[S any, T any](x *Sampler[S, T], size int) bool {
+	x.queueMux.Lock()
+	defer x.queueMux.Unlock()
+
+	if x.closed.Load() {
+		return false
+	}
+	return replaceQueue(x, size)
+}
+
+// replaceQueue replaces the queue with a larger one of the given size, keeping queued samples.
+// Returns false if the size is not larger than the current one, or exceeds MaxQueueSize.
+// Must be called with queueMux locked.
+func replaceQueue[S any, T any](x *Sampler[S, T], size int) bool {
+	old := *x.sampleChan.Load()
+	if size <= cap(old) || size > MaxQueueSize {
+		return false
+	}
+
+	// the processing goroutine moves on to the new queue once it has drained the old one
+	ch := make(chan item[T], size)
+	x.sampleChan.Store(&ch)
+	x.successorsMux.Lock()
+	if x.successors == nil {
+		x.successors = make(map[chan item[T]]chan item[T])
+	}
+	x.successors[old] = ch
+	x.successorsMux.Unlock()
+	close(old)
+	return true
+}
+
+// MaxQueueSize is the largest queue size OnOverflow may request.
+const MaxQueueSize = 1 << 20
+
+// SamplerStats describes the condition of a Sampler.
+type SamplerStats struct {
+	Queued     int // samples currently waiting in the queue
+	Capacity   int // queue size
+	Dropped    uint64
+	Gated      uint64
+	Unsampled  uint64
+	OutOfOrder uint64
+}
+
+// Stats returns the current condition of the Sampler.
+func Stats[S any, T any](x *Sampler[S, T]) SamplerStats {
+	return stats(x)
+}
+
+func stats[S any, T any](x *Sampler[S, T]) SamplerStats {
+	ch := queue(x)
+	return SamplerStats{
+		Queued:     len(ch),
+		Capacity:   cap(ch),
+		Dropped:    x.drops.Load(),
+		Gated:      x.gated.Load(),
+		Unsampled:  x.unsampled.Load(),
+		OutOfOrder: x.outOfOrder.Load(),
+	}
+}
+
+// queue returns the current sample queue.
+func queue[S any, T any](x *Sampler[S, T]) chan item[T] {
+	return *x.sampleChan.Load()
+}
+
+// Dropped returns the number of samples discarded due to queue overflow.
+func Dropped[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.drops.Load()
+}
+
+// DroppedSamples returns the most recent samples discarded after an overflow, oldest first.
+// Retains at most KeepDropped samples.
+func DroppedSamples[S any, T any](x *Sampler[S, T]) []T {
+	x.droppedMux.Lock()
+	o := x.dropped.slice()
+	x.droppedMux.Unlock()
+	return o
+}
+
+// drop accounts for a sample discarded due to overflow.
+func drop[S any, T any](x *Sampler[S, T], v T) {
+	x.drops.Add(1)
+	if x.KeepDropped > 0 {
+		keepDropped(x, v)
+	}
+}
+
+// dropItem counts all samples of an item as dropped.
+func dropItem[S any, T any](x *Sampler[S, T], it item[T]) {
+	if it.batch == nil {
+		drop(x, it.v)
+		return
+	}
+	for _, v := range it.batch {
+		drop(x, v)
+	}
+}
+
+func keepDropped[S any, T any](x *Sampler[S, T], v T) {
+	x.droppedMux.Lock()
+	if x.dropped.values == nil {
+		x.dropped = ringMake[T](x.KeepDropped)
+	}
+	x.dropped.push(v)
+	x.droppedMux.Unlock()
 }
 
+// SetWatermarks makes the Sampler recover from overflows, instead of shutting down.
+// Once the queue depth reaches the high fraction of its capacity, the Sampler enters overload and discards new samples,
+// until the processing goroutine works the depth down to the low fraction.
+// The gap between the two prevents flapping in and out of overload while the queue hovers near the limit.
+//
+// Must be called before Start. Requires 0 <= low < high <= 1.
+func SetWatermarks[S any, T any](x *Sampler[S, T], high, low float64) error {
+	if !(0 <= low && low < high && high <= 1) {
+		return fmt.Errorf("invalid watermarks: high %v, low %v", high, low)
+	}
+
+	n := float64(cap(queue(x)))
+	x.highMark = max(int(math.Ceil(high*n)), 1)
+	x.lowMark = min(int(low*n), x.highMark-1)
+	return nil
+}
+
+// SetAdaptiveDownsample makes the processing goroutine skip samples while it falls behind, trading accuracy for throughput.
+// Once the queue depth exceeds the highWater fraction of its capacity, only every Kth sample is processed,
+// with K growing along with the depth up to maxSkip for a full queue, and returning to 1 as the depth falls back.
+// This keeps approximate aggregates (e.g. averages, or sums scaled by K) going under sustained load.
+//
+// Must be called before Start. Requires 0 <= highWater < 1 and maxSkip >= 2.
+func SetAdaptiveDownsample[S any, T any](x *Sampler[S, T], highWater float64, maxSkip int) error {
+	if !(0 <= highWater && highWater < 1) || maxSkip < 2 {
+		return fmt.Errorf("invalid downsampling: high water %v, max skip %v", highWater, maxSkip)
+	}
+
+	x.downsampleHigh = highWater
+	x.maxSkip = maxSkip
+	x.skipFactor.Store(1)
+	return nil
+}
+
+// SkipFactor returns the current downsampling factor K, meaning that one in K samples is being processed.
+// Always 1 unless adaptive downsampling is enabled.
+func SkipFactor[S any, T any](x *Sampler[S, T]) int {
+	return max(int(x.skipFactor.Load()), 1)
+}
+
+// Skipped returns the number of samples skipped by adaptive downsampling.
+func Skipped[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.skipped.Load()
+}
+
+var (
+	activeSamplers    atomic.Int64
+	maxActiveSamplers atomic.Int64
+)
+
+// ActiveSamplers returns the number of Sampler processing goroutines currently running.
+func ActiveSamplers() int {
+	return int(activeSamplers.Load())
+}
+
+// SetMaxActiveSamplers limits the number of Sampler processing goroutines that may run at once, guarding against
+// accidentally launching one per high cardinality key. A limit of 0 (the default) means no limit.
+// Lowering the limit doesn't affect Samplers that are already running.
+func SetMaxActiveSamplers(n int) {
+	maxActiveSamplers.Store(int64(n))
+}
+
+// Start launches the processing loop.
+// NoOp if the Sampler has already been started or stopped.
+// If the SetMaxActiveSamplers limit has been reached, the Sampler is not started, and a warning is emitted.
 func Start[S any, T any](x *Sampler[S, T]) {
-	x.inactive = false
-	go loop(x)
+	if err := StartErr(x); err != nil {
+		warn("obs: sampler not started", "err", err)
+	}
+}
+
+// StartErr is like Start, but returns ErrLimit instead of warning if the active Sampler limit has been reached.
+// The Sampler may be started again once others have stopped.
+func StartErr[S any, T any](x *Sampler[S, T]) error {
+	if x.started.Load() || x.closed.Load() {
+		return nil
+	}
+
+	// serialized with deactivate, which must know whether a processing goroutine will take care of the queue
+	x.queueMux.Lock()
+	defer x.queueMux.Unlock()
+
+	if x.started.Load() || x.closed.Load() {
+		// lost a race with another Start or Stop
+		return nil
+	}
+
+	for {
+		n := activeSamplers.Load()
+		if limit := maxActiveSamplers.Load(); limit > 0 && n >= limit {
+			return ErrLimit
+		}
+		if activeSamplers.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+
+	x.started.Store(true)
+	x.inactive.Store(false)
+	go loop(x, queue(x))
+	return nil
+}
+
+// Gate opens or closes the Sampler's gate. While closed, the producer side discards all samples without queuing them,
+// counting them as gated. The processing goroutine is not affected, and keeps working through the queue.
+func Gate[S any, T any](x *Sampler[S, T], open bool) {
+	x.gateClosed.Store(!open)
+}
+
+// Gated returns the number of samples discarded due to a closed gate.
+func Gated[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.gated.Load()
+}
+
+// Pause closes the Sampler's gate, suspending sample acceptance until Resume.
+// Unlike Stop, it keeps the processing goroutine and the state, so it can be used to switch instrumentation off at runtime.
+// Samples discarded in the meantime are counted by Gated.
+func Pause[S any, T any](x *Sampler[S, T]) {
+	Gate(x, false)
+}
+
+// Paused reports whether the Sampler's gate is closed.
+func Paused[S any, T any](x *Sampler[S, T]) bool {
+	return x.gateClosed.Load()
+}
+
+// Resume reopens the Sampler's gate after Pause.
+func Resume[S any, T any](x *Sampler[S, T]) {
+	Gate(x, true)
+}
+
+// History returns the most recently processed samples, oldest first.
+// Retains at most KeepHistory samples.
+func History[S any, T any](x *Sampler[S, T]) []T {
+	x.historyMux.Lock()
+	o := x.history.slice()
+	x.historyMux.Unlock()
+	return o
+}
+
+// Merge folds snapshots of the source Samplers' states into the destination's state, using the combine function.
+// Safe to use while the Samplers are running.
+func Merge[S any, T any](dst *Sampler[S, T], srcs []*Sampler[S, T], combine func(dst *S, src S)) {
+	for _, src := range srcs {
+		v := Snapshot(src)
+
+		dst.stateMux.Lock()
+		combine(dst.state, v)
+		dst.stateMux.Unlock()
+	}
+}
+
+// OutOfOrder returns the number of processed samples whose timestamp preceded that of the previous sample.
+// Always 0 if the Sampler has no Timestamp function.
+func OutOfOrder[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.outOfOrder.Load()
+}
+
+// A Cloner state provides its own deep copies, used by Snapshot instead of a shallow copy.
+// States that hold references to memory modified during processing (slices, maps) should implement it.
+type Cloner[S any] interface {
+	Clone() S
+}
+
+// A Resetter state knows how to reset itself, used by ResetState instead of zeroing.
+// Useful for states that carry configuration alongside the aggregate.
+type Resetter interface {
+	Reset()
+}
+
+// ResetState sets the Sampler's state to its zero value, or resets it if it is a Resetter.
+// Safe to use while the Sampler is running; the reset takes place between samples.
+func ResetState[S any, T any](x *Sampler[S, T]) {
+	x.stateMux.Lock()
+	resetState(x.state)
+	x.stateMux.Unlock()
+}
+
+// Snapshot returns a copy of the Sampler's current state, which is shallow unless the state is a Cloner.
+// Safe to use while the Sampler is running.
+func Snapshot[S any, T any](x *Sampler[S, T]) S {
+	x.stateMux.Lock()
+	o := copyState(x.state)
+	x.stateMux.Unlock()
+	return o
+}
+
+// SnapshotReset is like Snapshot, but atomically resets the state as ResetState would.
+func SnapshotReset[S any, T any](x *Sampler[S, T]) S {
+	x.stateMux.Lock()
+	o := copyState(x.state)
+	resetState(x.state)
+	x.stateMux.Unlock()
+	return o
+}
+
+// SamplerValue returns a Value whose Loader derives a value from the Sampler's live state using get,
+// under the same lock the processing goroutine works on it with, so that it may be exposed in a Map.
+// This spares copying the whole state, unlike Snapshot. get runs between samples, and must not retain the state,
+// or any memory it references, beyond the call.
+func SamplerValue[S any, T any](x *Sampler[S, T], label string, get func(*S) any) Value {
+	return Value{
+		Label:  label,
+		Loader: stateLoader[S, T]{x, get},
+	}
+}
+
+type stateLoader[S any, T any] struct {
+	x   *Sampler[S, T]
+	get func(*S) any
+}
+
+func (x stateLoader[S, T]) Load() any {
+	x.x.stateMux.Lock()
+	defer x.x.stateMux.Unlock()
+	return x.get(x.x.state)
+}
+
+// MarshalState JSON encodes the Sampler's state, for checkpointing. S must be JSON serializable.
+// Safe to use while the Sampler is running, as by WriteCheckpoint.
+func MarshalState[S any, T any](x *Sampler[S, T]) ([]byte, error) {
+	var o []byte
+	err := encodeState(x, func(s *S) error {
+		var err error
+		o, err = json.Marshal(s)
+		return err
+	})
+	return o, err
+}
+
+// RestoreState replaces the Sampler's state with one decoded from data, as produced by MarshalState,
+// so that aggregation can resume from a checkpoint. Only allowed before Start; returns ErrStarted afterwards.
+func RestoreState[S any, T any](x *Sampler[S, T], data []byte) error {
+	if x.started.Load() {
+		return ErrStarted
+	}
+
+	var s S
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	x.stateMux.Lock()
+	*x.state = s
+	x.stateMux.Unlock()
+	return nil
+}
+
+func copyState[S any](s *S) S {
+	if c, ok := any(s).(Cloner[S]); ok {
+		return c.Clone()
+	}
+	return *s
+}
+
+func resetState[S any](s *S) {
+	if r, ok := any(s).(Resetter); ok {
+		r.Reset()
+		return
+	}
+	var zero S
+	*s = zero
 }
 
 // Stop terminates the active processing loop, if it exists.
 // Must be called when the Sampler is no longer needed.
+// Subsequent calls are NoOps.
 func Stop[S any, T any](x *Sampler[S, T]) {
-	x.inactive = true
-	close(x.sampleChan)
+	x.stopOnce.Do(func() {
+		if x.OnStop != nil && x.started.Load() {
+			x.stateMux.Lock()
+			x.OnStop(x.state)
+			x.stateMux.Unlock()
+		}
+	})
+	deactivate(x)
+}
+
+// StopAndWait is like Stop, but also waits for the remaining samples to be processed, and Final to return.
+func StopAndWait[S any, T any](x *Sampler[S, T]) {
+	Stop(x)
+	<-x.done
+}
+
+// StopAndCollect is like StopAndWait, but also returns the final state.
+func StopAndCollect[S any, T any](x *Sampler[S, T]) S {
+	StopAndWait(x)
+	return Snapshot(x)
+}
+
+// StopAndCollectTimeout is like StopAndCollect, but gives up waiting after d, returning an error wrapping ErrTimeout
+// along with the state as it currently is. This bounds shutdown in the face of a misbehaving sample function or Final.
+//
+// The state can only be obtained if the processing goroutine isn't currently working on it;
+// if it is stuck doing so, the zero value is returned instead.
+func StopAndCollectTimeout[S any, T any](x *Sampler[S, T], d time.Duration) (S, error) {
+	err := stopWithin(x, d)
+	if err == nil {
+		return Snapshot(x), nil
+	}
+
+	var o S
+	if x.stateMux.TryLock() {
+		o = copyState(x.state)
+		x.stateMux.Unlock()
+	}
+	return o, err
 }
 
-func loop[S any, T any](x *Sampler[S, T]) {
+// stopWithin stops the Sampler and waits up to d for it to finish, as by StopAndCollectTimeout and Drain.
+func stopWithin[S any, T any](x *Sampler[S, T], d time.Duration) error {
+	Stop(x)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-x.done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("draining sampler: %w", ErrTimeout)
+	}
+}
+
+// deactivate marks the Sampler as inactive and closes its queue.
+// Returns false if this had already happened.
+func deactivate[S any, T any](x *Sampler[S, T]) bool {
+	o := false
+	x.closeOnce.Do(func() {
+		x.queueMux.Lock()
+		x.inactive.Store(true)
+		x.closed.Store(true)
+		close(queue(x))
+		if !x.started.Load() {
+			// there is no processing goroutine to work through buffered samples, or to signal completion
+			for it := range queue(x) {
+				it.done()
+			}
+			close(x.done)
+		}
+		x.queueMux.Unlock()
+		o = true
+	})
+	return o
+}
+
+func loop[S any, T any](x *Sampler[S, T], ch chan item[T]) {
+	defer close(x.done)
+	defer activeSamplers.Add(-1)
+
 	if x.Final != nil {
 		defer func() {
-			x.Final(&x.state)
+			x.stateMux.Lock()
+			x.Final(x.state)
+			x.stateMux.Unlock()
 		}()
 	}
 
-	if x.First != nil {
-		sample, ok := <-x.sampleChan
+	if x.OnStart != nil {
+		x.stateMux.Lock()
+		x.OnStart(x.state)
+		x.stateMux.Unlock()
+	}
+
+	if x.OnWindow != nil && x.Window > 0 {
+		defer windows(x)()
+	}
+
+	if x.checkpointInterval > 0 {
+		defer checkpoint(x)
+	}
+
+	w := watchdogMake(x)
+	defer w.stop()
+
+	if x.equal != nil {
+		loopCoalesce(x, ch, w)
+		return
+	}
+
+	first := x.First != nil
+	if x.shards != nil {
+		w.onFlush = func() {
+			flushShards(x, ch, &first)
+		}
+		w.flushTicker = clockOr(x.Clock).NewTicker(x.shardFlush)
+	}
+	for {
+		it, ok := receive(ch, w)
 		if !ok {
+			if ch, ok = nextQueue(x, ch); ok {
+				continue
+			}
+			if x.shards != nil {
+				flushShards(x, ch, &first)
+			}
 			return
 		}
 
-		x.First(&x.state, sample)
-		x.sampleFunc(&x.state, sample)
+		if it.flush {
+			if x.shards != nil {
+				flushShards(x, ch, &first)
+			}
+			it.done()
+			continue
+		}
+
+		if it.batch != nil {
+			processBatch(x, ch, it.batch, &first)
+			it.done()
+			checkDepth(x, ch)
+			continue
+		}
+
+		if downsample(x, ch) {
+			it.done()
+			continue
+		}
+
+		observe(x, it.v)
+		x.stateMux.Lock()
+		if first {
+			x.First(x.state, it.v)
+			first = false
+		}
+		if x.MeasureProcessing {
+			clock := clockOr(x.Clock)
+			start := clock.Now()
+			(*x.sampleFunc.Load())(x.state, it.v)
+			x.processing.add(clock.Now().Sub(start))
+		} else {
+			(*x.sampleFunc.Load())(x.state, it.v)
+		}
+		x.stateMux.Unlock()
+		it.done()
+
+		checkDepth(x, ch)
+	}
+}
+
+// nextQueue returns the queue that replaced a closed one.
+// Returns false if the queue was closed for good.
+func nextQueue[S any, T any](x *Sampler[S, T], closed chan item[T]) (chan item[T], bool) {
+	x.successorsMux.Lock()
+	ch, ok := x.successors[closed]
+	delete(x.successors, closed)
+	x.successorsMux.Unlock()
+	return ch, ok
+}
+
+func loopCoalesce[S any, T any](x *Sampler[S, T], ch chan item[T], w *watchdog) {
+	var (
+		run   T
+		n     int             // length of the pending run
+		acks  []chan struct{} // acknowledgements of the pending run
+		first = x.First != nil
+	)
+
+	flush := func() {
+		processRun(x, run, n)
+		for _, ack := range acks {
+			close(ack)
+		}
+		n, acks = 0, acks[:0]
 	}
 
-	for sample := range x.sampleChan {
-		x.sampleFunc(&x.state, sample)
+	// take adds a sample to the pending run, flushing the previous one if it ends.
+	// Returns false if the sample was skipped.
+	take := func(ch chan item[T], v T) bool {
+		if downsample(x, ch) {
+			return false
+		}
 
-		if len(x.sampleChan) == cap(x.sampleChan) {
+		observe(x, v)
+		if first {
+			x.stateMux.Lock()
+			x.First(x.state, v)
+			x.stateMux.Unlock()
+			first = false
+		}
+
+		if n > 0 && !x.equal(run, v) {
+			flush()
+		}
+		if n == 0 {
+			run = v
+		}
+		n++
+		return true
+	}
+
+	for {
+		var (
+			it item[T]
+			ok bool
+		)
+		if n == 0 {
+			it, ok = receive(ch, w)
+		} else {
+			select {
+			case it, ok = <-ch:
+			default:
+				// queue is empty, don't hold back the pending run
+				flush()
+				continue
+			}
+		}
+
+		if !ok {
+			if ch, ok = nextQueue(x, ch); ok {
+				continue
+			}
+			if n > 0 {
+				flush()
+			}
+			return
+		}
+
+		if it.flush {
+			if n > 0 {
+				flush()
+			}
+			it.done()
+			continue
+		}
+
+		taken := false
+		if it.batch != nil {
+			for _, v := range it.batch {
+				taken = take(ch, v) || taken
+			}
+		} else {
+			taken = take(ch, it.v)
+		}
+		if !taken {
+			it.done()
+			continue
+		}
+
+		it.quota.release()
+		if it.ack != nil {
+			acks = append(acks, it.ack)
+		}
+
+		checkDepth(x, ch)
+	}
+}
+
+// checkDepth performs consumer side checks on the queue depth, after a sample has been taken from it.
+func checkDepth[S any, T any](x *Sampler[S, T], ch chan item[T]) {
+	depth := len(ch)
+	if x.OnHighWater != nil || x.OnLowWater != nil || x.AutoGrow {
+		checkWater(x, ch, depth)
+	}
+
+	if x.Strategy != nil {
+		return
+	}
+	if x.highMark == 0 {
+		if depth == cap(ch) && ch == queue(x) {
 			// we have reached overflow
-			x.stop = true
+			x.stop.Store(true)
+		}
+		return
+	}
+
+	if x.overloaded.Load() {
+		if depth <= x.lowMark {
+			x.overloaded.Store(false)
+		}
+	} else if depth >= x.highMark {
+		overload(x)
+	}
+}
+
+// checkWater tracks high water crossings of a queue depth.
+func checkWater[S any, T any](x *Sampler[S, T], ch chan item[T], depth int) {
+	if !x.aboveHighWater {
+		if float64(depth) < x.HighWater*float64(cap(ch)) {
+			return
+		}
+		x.aboveHighWater = true
+		if x.OnHighWater != nil {
+			x.OnHighWater(depth, cap(ch))
+		}
+		if x.AutoGrow && x.growing.CompareAndSwap(false, true) {
+			// growing has to wait for blocked producers, which in turn may be waiting for this goroutine
+			go func() {
+				growTo(x, min(2*cap(ch), MaxQueueSize))
+				x.growing.Store(false)
+			}()
+		}
+		return
+	}
+
+	if x.LowWater > 0 {
+		if float64(depth) > x.LowWater*float64(cap(ch)) {
+			return
+		}
+	} else if float64(depth) >= x.HighWater*float64(cap(ch)) {
+		return
+	}
+	x.aboveHighWater = false
+	if x.OnLowWater != nil {
+		x.OnLowWater(depth, cap(ch))
+	}
+}
+
+// downsample updates the downsampling factor according to the queue depth, after a sample has been taken from it.
+// Returns true if the sample should be skipped.
+func downsample[S any, T any](x *Sampler[S, T], ch chan item[T]) bool {
+	if x.maxSkip == 0 {
+		return false
+	}
+
+	k := 1
+	limit := float64(cap(ch))
+	threshold := x.downsampleHigh * limit
+	if depth := float64(len(ch)); depth > threshold {
+		k = 1 + int(math.Round(float64(x.maxSkip-1)*(depth-threshold)/(limit-threshold)))
+		k = min(k, x.maxSkip)
+	}
+	x.skipFactor.Store(int64(k))
+
+	x.skipPos++
+	if x.skipPos < k {
+		x.skipped.Add(1)
+		return true
+	}
+	x.skipPos = 0
+	return false
+}
+
+// overload puts the Sampler in overload, if it isn't already.
+func overload[S any, T any](x *Sampler[S, T]) {
+	if x.overloaded.CompareAndSwap(false, true) && x.Overflow != nil {
+		x.Overflow()
+	}
+}
+
+// observe performs consumer side checks on a sample, before it is processed.
+func observe[S any, T any](x *Sampler[S, T], v T) {
+	if x.KeepHistory > 0 {
+		x.historyMux.Lock()
+		if x.history.values == nil {
+			x.history = ringMake[T](x.KeepHistory)
+		}
+		x.history.push(v)
+		x.historyMux.Unlock()
+	}
+	if x.Log != nil {
+		x.Log.record(v)
+	}
+
+	if x.DebugAssertOrdered && x.Sequence != nil {
+		seq := x.Sequence(v)
+		if seq < x.lastSeq {
+			panic(fmt.Sprintf("obs: sample out of order: sequence %d after %d", seq, x.lastSeq))
+		}
+		x.lastSeq = seq
+	}
+
+	if x.Timestamp == nil {
+		return
+	}
+
+	t := x.Timestamp(v)
+	if t.Before(x.lastTime) {
+		x.outOfOrder.Add(1)
+		if x.OnOutOfOrder != nil {
+			x.OnOutOfOrder(x.lastTime, t)
 		}
 	}
+	x.lastTime = t
+}
+
+func processRun[S any, T any](x *Sampler[S, T], v T, n int) {
+	x.stateMux.Lock()
+	if x.MeasureProcessing {
+		clock := clockOr(x.Clock)
+		start := clock.Now()
+		(*x.runFunc.Load())(x.state, v, n)
+		x.processing.add(clock.Now().Sub(start))
+	} else {
+		(*x.runFunc.Load())(x.state, v, n)
+	}
+	x.stateMux.Unlock()
 }
 
+// processBatch processes the samples of a batch in order, under a single lock.
+func processBatch[S any, T any](x *Sampler[S, T], ch chan item[T], vs []T, first *bool) {
+	// the batch is private to the Sampler, so skipped samples can be filtered out in place
+	kept := vs[:0]
+	for _, v := range vs {
+		if downsample(x, ch) {
+			continue
+		}
+		observe(x, v)
+		kept = append(kept, v)
+	}
+	if len(kept) == 0 {
+		return
+	}
+
+	x.stateMux.Lock()
+	defer x.stateMux.Unlock()
+
+	if *first {
+		x.First(x.state, kept[0])
+		*first = false
+	}
+
+	var clock Clock
+	var start time.Time
+	if x.MeasureProcessing {
+		clock = clockOr(x.Clock)
+		start = clock.Now()
+	}
+	if batchFunc := x.batchFunc.Load(); batchFunc != nil {
+		(*batchFunc)(x.state, kept)
+	} else {
+		sampleFunc := *x.sampleFunc.Load()
+		for _, v := range kept {
+			sampleFunc(x.state, v)
+		}
+	}
+	if x.MeasureProcessing {
+		x.processing.add(clock.Now().Sub(start))
+	}
+}
+
+// An item is a queued sample, or batch of samples.
+type item[T any] struct {
+	v     T
+	batch []T           // if non-nil, the samples of a batch, in place of v
+	ack   chan struct{} // closed once the sample is done with, if non-nil
+	quota *Quota        // released once the sample is done with, if non-nil
+	flush bool          // a Flush marker, carrying no sample
+	shard bool          // a full shard buffer, whose samples were already accepted from their producers' point of view
+}
+
+func (x item[T]) done() {
+	x.quota.release()
+	if x.ack != nil {
+		close(x.ack)
+	}
+}
+
+// A Value is a labeled Loader, along with optional metadata for exporters.
 type Value struct {
 	Label string
 	Loader
+
+	Help string // human readable description, omitted from exports if empty
+	Unit string // unit of measurement (e.g. "seconds"), omitted from exports if empty
+
+	Tags map[string]string // constant dimensions (e.g. "region": "eu"), emitted in each exporter's native syntax
+
+	Visibility Visibility // lowest export level at which the Value is included; Public by default
+}
+
+// A Visibility level restricts which Values an export includes.
+// An export at a given level includes all Values of that level or lower.
+type Visibility int
+
+const (
+	Public Visibility = iota // exposed everywhere
+	Debug                    // internal diagnostics, only exposed by debug level exports
+)
+
+// A LabeledError is an error concerning a specific Value.
+type LabeledError struct {
+	Label string
+	Err   error
+}
+
+func (x LabeledError) Error() string {
+	return x.Label + ": " + x.Err.Error()
+}
+
+func (x LabeledError) Unwrap() error {
+	return x.Err
 }
@@ -0,0 +1,861 @@
+package obs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// summing returns a Sampler adding up its samples.
+func summing(queueSize int) *Sampler[int, int] {
+	return SamplerMake(queueSize, func(s *int, v int) { *s += v })
+}
+
+func TestStopTwice(t *testing.T) {
+	x := summing(1)
+	Start(x)
+	Stop(x)
+	Stop(x)
+	x.Stop()
+	<-x.Done()
+
+	// never started
+	y := summing(1)
+	Stop(y)
+	Stop(y)
+}
+
+func TestSampleBeforeStart(t *testing.T) {
+	x := summing(4)
+	if err := SampleErr(x, 1); err != ErrNotStarted {
+		t.Errorf("lossy: got %v, want ErrNotStarted", err)
+	}
+	Start(x)
+	Sample(x, 2)
+	if got := StopAndCollect(x); got != 2 {
+		t.Errorf("lossy: got %d, want the pre-Start sample discarded", got)
+	}
+}
+
+func TestSampleBeforeStartBuffered(t *testing.T) {
+	x := summing(2)
+	x.Buffer = true
+	for _, v := range []int{1, 2} {
+		if err := SampleErr(x, v); err != nil {
+			t.Fatalf("buffering %d: %v", v, err)
+		}
+	}
+	if err := SampleErr(x, 4); err != ErrNotStarted {
+		t.Errorf("buffer full: got %v, want ErrNotStarted", err)
+	}
+	Start(x)
+	Sample(x, 8)
+	if got := StopAndCollect(x); got != 11 {
+		t.Errorf("got %d, want the buffered samples processed", got)
+	}
+}
+
+func TestSamplerMakeState(t *testing.T) {
+	var total int
+	x := SamplerMakeState(4, &total, func(s *int, v int) { *s += v })
+	Start(x)
+	Sample(x, 1)
+	Sample(x, 2)
+	StopAndWait(x)
+	if total != 3 {
+		t.Errorf("external state: got %d, want 3", total)
+	}
+}
+
+type run struct {
+	v, n int
+}
+
+func TestSamplerMakeCoalesce(t *testing.T) {
+	x := SamplerMakeCoalesce(16, func(a, b int) bool { return a == b }, func(s *[]run, v int, n int) {
+		*s = append(*s, run{v, n})
+	})
+	// buffered before Start, so the whole sequence is queued at once
+	x.Buffer = true
+	for _, v := range []int{1, 1, 1, 2, 3, 3, 1} {
+		Sample(x, v)
+	}
+	Start(x)
+
+	got := StopAndCollect(x)
+	if want := []run{{1, 3}, {2, 1}, {3, 2}, {1, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSnapshotReset(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	defer Stop(x)
+
+	Sample(x, 1)
+	Sample(x, 2)
+	Flush(x)
+	if got := SnapshotReset(x); got != 3 {
+		t.Errorf("first snapshot: got %d, want 3", got)
+	}
+
+	Sample(x, 4)
+	Flush(x)
+	if got := SnapshotReset(x); got != 4 {
+		t.Errorf("second snapshot: got %d, want 4 from a reset state", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	srcs := []*Sampler[int, int]{summing(4), summing(4), summing(4)}
+	for i, x := range srcs {
+		Start(x)
+		Sample(x, i+1)
+		Sample(x, 10)
+		StopAndWait(x)
+	}
+
+	dst := summing(1)
+	Merge(dst, srcs, func(dst *int, src int) { *dst += src })
+	if got := Snapshot(dst); got != 36 {
+		t.Errorf("got %d, want 36", got)
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+
+	x := SamplerMake(8, func(s *int, v time.Time) { *s++ })
+	x.Timestamp = func(v time.Time) time.Time { return v }
+	var reports [][2]time.Time
+	x.OnOutOfOrder = func(prev, cur time.Time) {
+		reports = append(reports, [2]time.Time{prev, cur})
+	}
+
+	Start(x)
+	for _, sec := range []int64{1, 2, 2, 1, 3, 0} {
+		Sample(x, at(sec))
+	}
+	StopAndWait(x)
+
+	if n := OutOfOrder(x); n != 2 {
+		t.Errorf("counted %d out of order samples, want 2", n)
+	}
+	if len(reports) != 2 || reports[0] != [2]time.Time{at(2), at(1)} || reports[1] != [2]time.Time{at(3), at(0)} {
+		t.Errorf("reported %v", reports)
+	}
+	if got := Snapshot(x); got != 6 {
+		t.Errorf("processed %d samples, want all 6", got)
+	}
+}
+
+// blocked returns a Sampler whose sample function blocks on the sample 0 until released,
+// along with a channel that is closed once it starts doing so.
+func blocked(queueSize int) (x *Sampler[int, int], busy <-chan struct{}, release chan<- struct{}) {
+	b := make(chan struct{})
+	r := make(chan struct{})
+	x = SamplerMake(queueSize, func(s *int, v int) {
+		if v == 0 {
+			close(b)
+			<-r
+		}
+		*s += v
+	})
+	return x, b, r
+}
+
+func TestDroppedSamples(t *testing.T) {
+	x, busy, release := blocked(2)
+	x.Strategy = ShutdownOnOverflow
+	x.KeepDropped = 3
+	Start(x)
+
+	Sample(x, 0)
+	<-busy
+	for i := 1; i <= 7; i++ {
+		Sample(x, i) // 1 and 2 fit in the queue, 3 overflows it, the rest find the Sampler shut down
+	}
+	close(release)
+	StopAndWait(x)
+
+	if got := Dropped(x); got != 5 {
+		t.Errorf("dropped %d samples, want 5", got)
+	}
+	if got, want := DroppedSamples(x), []int{5, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("retained %v, want the most recent %v", got, want)
+	}
+}
+
+func TestSetFunc(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	Sample(x, 1)
+	Sample(x, 2)
+	Flush(x)
+
+	SetFunc(x, func(s *int, v int) { *s += 10 * v })
+	Sample(x, 3)
+	if got := StopAndCollect(x); got != 33 {
+		t.Errorf("got %d, want samples before the swap added as is, and after it multiplied", got)
+	}
+}
+
+func TestFoldOnOverflow(t *testing.T) {
+	x, busy, release := blocked(2)
+	var folds int
+	x.FoldOnOverflow = func(s *int, v int) {
+		folds++
+		*s += v
+	}
+	Start(x)
+
+	Sample(x, 0)
+	<-busy
+	// folding waits for the state lock, held by the blocked sample function
+	go close(release)
+	want := 0
+	for i := 1; i <= 100; i++ {
+		if err := SampleErr(x, i); err != nil {
+			t.Fatalf("sample %d: %v", i, err)
+		}
+		want += i
+	}
+
+	if got := StopAndCollect(x); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if folds == 0 {
+		t.Error("no sample was folded")
+	}
+	if d := Dropped(x); d != 0 {
+		t.Errorf("dropped %d samples", d)
+	}
+}
+
+func TestGate(t *testing.T) {
+	x := summing(4)
+	Start(x)
+
+	Sample(x, 1)
+	Gate(x, false)
+	if err := SampleErr(x, 2); err != ErrGated {
+		t.Errorf("closed gate: got %v, want ErrGated", err)
+	}
+	Sample(x, 4)
+	Gate(x, true)
+	Sample(x, 8)
+
+	if got := StopAndCollect(x); got != 9 {
+		t.Errorf("got %d, want only the samples pushed while open", got)
+	}
+	if n := Gated(x); n != 2 {
+		t.Errorf("gated %d samples, want 2", n)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	x := SamplerMake(8, func(s *[]int, v int) { *s = append(*s, v) })
+	x.Transform = func(v int) int { return v * v }
+	Start(x)
+	Sample(x, 2)
+	SampleBatch(x, []int{3, 4})
+	if got := StopAndCollect(x); !reflect.DeepEqual(got, []int{4, 9, 16}) {
+		t.Errorf("got %v, want every sample transformed", got)
+	}
+
+	y := SamplerMake(8, func(s *[]int, v int) { *s = append(*s, v) })
+	Start(y)
+	Sample(y, 2)
+	if got := StopAndCollect(y); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("nil Transform: got %v", got)
+	}
+}
+
+func TestLifecycleCallbacks(t *testing.T) {
+	var calls []string
+	x := SamplerMake(4, func(s *int, v int) {
+		calls = append(calls, "sample")
+	})
+	x.OnStart = func(*int) { calls = append(calls, "OnStart") }
+	x.First = func(*int, int) { calls = append(calls, "First") }
+	x.OnStop = func(*int) { calls = append(calls, "OnStop") }
+	x.Final = func(*int) { calls = append(calls, "Final") }
+
+	Start(x)
+	Sample(x, 1)
+	Sample(x, 2)
+	Flush(x)
+	Stop(x)
+	Stop(x)
+	<-x.Done()
+
+	want := []string{"OnStart", "First", "sample", "sample", "OnStop", "Final"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %v, want %v", calls, want)
+	}
+}
+
+// stepped returns a Sampler whose sample function processes one sample per receive from the returned channel.
+// Closing the channel lets it run freely.
+func stepped(queueSize int) (*Sampler[int, int], chan struct{}) {
+	step := make(chan struct{})
+	x := SamplerMake(queueSize, func(s *int, v int) {
+		<-step
+		*s += v
+	})
+	return x, step
+}
+
+func TestWatermarksSteady(t *testing.T) {
+	x, step := stepped(10)
+	if err := SetWatermarks(x, 0.8, 0.2); err != nil {
+		t.Fatal(err)
+	}
+	var overflows int
+	x.Overflow = func() { overflows++ }
+	Start(x)
+
+	// hover around half the queue, between the marks
+	for i := 0; i < 5; i++ {
+		Sample(x, 1)
+	}
+	for i := 0; i < 50; i++ {
+		step <- struct{}{}
+		if err := SampleErr(x, 1); err != nil {
+			t.Fatalf("sample %d: %v", i, err)
+		}
+	}
+	close(step)
+
+	if got := StopAndCollect(x); got != 55 {
+		t.Errorf("got %d, want all 55 samples", got)
+	}
+	if overflows != 0 || Dropped(x) != 0 {
+		t.Errorf("overflowed %d times, dropped %d", overflows, Dropped(x))
+	}
+}
+
+func TestWatermarksRecovery(t *testing.T) {
+	x, step := stepped(10)
+	SetWatermarks(x, 0.8, 0.2)
+	var overflows int
+	x.Overflow = func() { overflows++ }
+	Start(x)
+
+	for i := 0; i < 12; i++ {
+		Sample(x, 1)
+	}
+	if err := SampleErr(x, 1); err != ErrOverloaded {
+		t.Fatalf("full queue: got %v, want ErrOverloaded", err)
+	}
+
+	close(step)
+	for deadline := time.Now().Add(5 * time.Second); SampleErr(x, 1) != nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no recovery from overload")
+		}
+	}
+	StopAndWait(x)
+	if overflows != 1 {
+		t.Errorf("entered overload %d times, want once", overflows)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	x := summing(16)
+	x.KeepHistory = 3
+	Start(x)
+	for i := 1; i <= 5; i++ {
+		Sample(x, i)
+	}
+	StopAndWait(x)
+
+	if got, want := History(x), []int{3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := History(summing(1)); len(got) != 0 {
+		t.Errorf("without KeepHistory: got %v", got)
+	}
+}
+
+func TestSampleAck(t *testing.T) {
+	x, busy, release := blocked(4)
+	Start(x)
+
+	first := SampleAck(x, 0)
+	<-busy
+	second := SampleAck(x, 1)
+	select {
+	case <-first:
+		t.Fatal("acknowledged while processing")
+	case <-second:
+		t.Fatal("acknowledged while queued")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-first
+	<-second
+	if got := Snapshot(x); got != 1 {
+		t.Errorf("acknowledged before processing: state %d", got)
+	}
+	StopAndWait(x)
+
+	select {
+	case <-SampleAck(x, 2):
+	default:
+		t.Error("discarded sample not acknowledged")
+	}
+}
+
+func TestClone(t *testing.T) {
+	run := func(clone func([]int) []int) [][]int {
+		x := SamplerMake(4, func(s *[][]int, v []int) { *s = append(*s, v) })
+		x.Clone = clone
+		// queued before Start, so none are processed before the buffer is reused
+		x.Buffer = true
+		buf := make([]int, 1)
+		for i := 1; i <= 3; i++ {
+			buf[0] = i
+			Sample(x, buf)
+		}
+		Start(x)
+		return StopAndCollect(x)
+	}
+
+	if got := run(nil); !reflect.DeepEqual(got, [][]int{{3}, {3}, {3}}) {
+		t.Errorf("without Clone: got %v, expected the aliased buffer", got)
+	}
+	clone := func(v []int) []int { return append([]int(nil), v...) }
+	if got := run(clone); !reflect.DeepEqual(got, [][]int{{1}, {2}, {3}}) {
+		t.Errorf("with Clone: got %v", got)
+	}
+}
+
+func TestOnOverflowGrow(t *testing.T) {
+	x, step := stepped(2)
+	var requests int
+	x.OnOverflow = func(s SamplerStats) (int, bool) {
+		requests++
+		return 4 * s.Capacity, true
+	}
+	Start(x)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 20; i++ {
+			if err := SampleErr(x, i); err != nil {
+				t.Errorf("sample %d: %v", i, err)
+			}
+		}
+	}()
+	// Let the producer block on the full queue before each step, so that the queue is still full after the
+	// processing goroutine takes a sample, which it considers an overflow.
+	for i := 0; i < 4; i++ {
+		time.Sleep(5 * time.Millisecond)
+		step <- struct{}{}
+	}
+	close(step)
+	<-done
+
+	if got := StopAndCollect(x); got != 210 {
+		t.Errorf("got %d, want all 20 samples processed", got)
+	}
+	if requests == 0 {
+		t.Error("OnOverflow not consulted")
+	}
+	if Dropped(x) != 0 || cap(queue(x)) <= 2 {
+		t.Errorf("dropped %d, queue size %d", Dropped(x), cap(queue(x)))
+	}
+}
+
+func TestStopAndCollect(t *testing.T) {
+	x := summing(8)
+	Start(x)
+	for i := 1; i <= 8; i++ {
+		Sample(x, i)
+	}
+	if got := StopAndCollect(x); got != 36 {
+		t.Errorf("got %d, want 36", got)
+	}
+}
+
+func TestHighWater(t *testing.T) {
+	x, step := stepped(10)
+	x.HighWater = 0.5
+	var crossings []int
+	x.OnHighWater = func(depth, cap int) {
+		if cap != 10 {
+			t.Errorf("reported capacity %d", cap)
+		}
+		crossings = append(crossings, depth)
+	}
+	Start(x)
+
+	// each burst fills the queue past half, then drains it while still hovering above for a few samples
+	for burst := 0; burst < 2; burst++ {
+		for i := 0; i < 9; i++ {
+			Sample(x, 1)
+		}
+		for i := 0; i < 9; i++ {
+			step <- struct{}{}
+		}
+	}
+	close(step)
+	StopAndWait(x)
+
+	if len(crossings) != 2 {
+		t.Errorf("fired at depths %v, want once per burst", crossings)
+	}
+	for _, depth := range crossings {
+		if depth < 5 {
+			t.Errorf("fired at depth %d, below the threshold", depth)
+		}
+	}
+}
+
+func TestAdaptiveDownsample(t *testing.T) {
+	var peak int
+	var x *Sampler[int, int]
+	x = SamplerMake(10, func(s *int, v int) {
+		peak = max(peak, SkipFactor(x))
+		time.Sleep(100 * time.Microsecond)
+		*s++
+	})
+	if err := SetAdaptiveDownsample(x, 0.5, 4); err != nil {
+		t.Fatal(err)
+	}
+	x.Strategy = BlockOnOverflow
+	Start(x)
+
+	// producers block on the full queue, keeping the consumer behind
+	const n = 200
+	for i := 0; i < n; i++ {
+		Sample(x, i)
+	}
+	processed := StopAndCollect(x)
+
+	if peak < 2 || peak > 4 {
+		t.Errorf("skip factor peaked at %d, want it to rise up to at most 4", peak)
+	}
+	if skipped := Skipped(x); skipped == 0 || uint64(processed)+skipped != n {
+		t.Errorf("processed %d and skipped %d of %d samples", processed, skipped, n)
+	}
+	if k := SkipFactor(x); k != 1 {
+		t.Errorf("skip factor %d once drained, want 1", k)
+	}
+}
+
+func TestActiveSamplers(t *testing.T) {
+	base := ActiveSamplers()
+	a, b := summing(1), summing(1)
+	Start(a)
+	Start(b)
+	Start(a) // NoOp
+	if n := ActiveSamplers() - base; n != 2 {
+		t.Errorf("after Start: got %d more, want 2", n)
+	}
+	StopAndWait(a)
+	if n := ActiveSamplers() - base; n != 1 {
+		t.Errorf("after Stop: got %d more, want 1", n)
+	}
+
+	SetMaxActiveSamplers(base + 1)
+	defer SetMaxActiveSamplers(0)
+	c := summing(1)
+	if err := StartErr(c); err != ErrLimit {
+		t.Errorf("at the limit: got %v, want ErrLimit", err)
+	}
+	if err := SampleErr(c, 1); err != ErrNotStarted {
+		t.Errorf("refused Sampler: got %v, want ErrNotStarted", err)
+	}
+
+	StopAndWait(b)
+	if err := StartErr(c); err != nil {
+		t.Errorf("below the limit: %v", err)
+	}
+	StopAndWait(c)
+	if n := ActiveSamplers(); n != base {
+		t.Errorf("after stopping all: got %d, want %d", n, base)
+	}
+}
+
+func TestStopAndCollectTimeout(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	Sample(x, 5)
+	if got, err := StopAndCollectTimeout(x, time.Second); err != nil || got != 5 {
+		t.Errorf("prompt drain: got %d, %v", got, err)
+	}
+
+	// stuck outside the sample function: the partial state is available
+	y := summing(4)
+	y.Log = SampleLogMake[int](10)
+	Start(y)
+	Sample(y, 1)
+	Sample(y, 2)
+	Flush(y)
+	y.Log.mux.Lock()
+	Sample(y, 4) // blocks the processing goroutine on the log
+	got, err := StopAndCollectTimeout(y, 10*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("stuck drain: got %v, want ErrTimeout", err)
+	}
+	if got != 3 {
+		t.Errorf("stuck drain: got state %d, want the partial 3", got)
+	}
+	y.Log.mux.Unlock()
+	<-y.Done()
+	if got := Snapshot(y); got != 7 {
+		t.Errorf("after unblocking: got %d, want 7", got)
+	}
+
+	// stuck inside the sample function: only the zero value is available
+	z, busy, release := blocked(4)
+	Start(z)
+	Sample(z, 1)
+	Sample(z, 0)
+	<-busy
+	got, err = StopAndCollectTimeout(z, 10*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) || got != 0 {
+		t.Errorf("stuck sample function: got %d, %v", got, err)
+	}
+	close(release)
+	<-z.Done()
+}
+
+func TestDebugAssertOrdered(t *testing.T) {
+	x := SamplerMake(8, func(s *int, v int) { *s += v })
+	x.DebugAssertOrdered = true
+	x.Sequence = func(v int) uint64 { return uint64(v) }
+
+	// in order, through the processing goroutine
+	Start(x)
+	for v := 1; v <= 4; v++ {
+		Sample(x, v)
+	}
+	if got := StopAndCollect(x); got != 10 {
+		t.Errorf("got %d, want 10", got)
+	}
+
+	// a deliberate reorder, checked directly, since a panic on the processing goroutine can't be recovered here
+	defer func() {
+		if recover() == nil {
+			t.Error("reordered sample: no panic")
+		}
+	}()
+	observe(x, 5)
+	observe(x, 3)
+}
+
+func TestConcurrentSampleStop(t *testing.T) {
+	for _, strategy := range []OverflowStrategy{nil, BlockOnOverflow, DropOnOverflow} {
+		x := summing(4)
+		x.Strategy = strategy
+		Start(x)
+
+		var accepted atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					if SampleErr(x, 1) == nil {
+						accepted.Add(1)
+					}
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		got := StopAndCollect(x) // must not panic producers, nor leave blocked ones behind
+		wg.Wait()
+		if int64(got) != accepted.Load() {
+			t.Errorf("strategy %v: processed %d, accepted %d", strategy, got, accepted.Load())
+		}
+	}
+}
+
+func TestSampleBatch(t *testing.T) {
+	x := SamplerMake(4, func(s *[]int, v int) { *s = append(*s, v) })
+	Start(x)
+	batch := []int{1, 2, 3}
+	if err := SampleBatch(x, batch); err != nil {
+		t.Fatal(err)
+	}
+	batch[0] = 100 // copied on push
+	SampleBatch(x, nil)
+	Flush(x)
+	Sample(x, 4)
+
+	var batches [][]int
+	SetBatchFunc(x, func(s *[]int, vs []int) {
+		batches = append(batches, vs)
+		*s = append(*s, -len(vs))
+	})
+	SampleBatch(x, []int{5, 6})
+	Sample(x, 7) // individual samples still use the sample function
+	got := StopAndCollect(x)
+
+	if want := []int{1, 2, 3, 4, -2, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := [][]int{{5, 6}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches: got %v, want %v", batches, want)
+	}
+}
+
+func TestSamplerValue(t *testing.T) {
+	x := SamplerMake(64, func(s *[]int, v int) { *s = append(*s, v) })
+	x.Strategy = BlockOnOverflow
+	m := MapMake()
+	m.Set("n", SamplerValue(x, "samples", func(s *[]int) any { return len(*s) }))
+	Start(x)
+
+	// loads race with processing
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			Sample(x, i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		m.Snapshot()
+	}
+	wg.Wait()
+	Flush(x)
+
+	if e := m.Snapshot(); len(e) != 1 || e[0].Label != "samples" || e[0].Value != 1000 {
+		t.Errorf("got %+v", e)
+	}
+	StopAndWait(x)
+}
+
+func TestLowWater(t *testing.T) {
+	x, step := stepped(10)
+	x.HighWater = 0.8
+	x.LowWater = 0.2
+	var events []string
+	x.OnHighWater = func(depth, cap int) { events = append(events, fmt.Sprint("high ", depth)) }
+	x.OnLowWater = func(depth, cap int) { events = append(events, fmt.Sprint("low ", depth)) }
+	Start(x)
+
+	for i := 0; i < 10; i++ {
+		Sample(x, 1)
+	}
+	for i := 0; i < 10; i++ {
+		step <- struct{}{}
+	}
+	close(step)
+	StopAndWait(x)
+
+	// stays above high water until the depth drops to the low water mark, rather than just below 8
+	if want := []string{"high 9", "low 2"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v, want %v", events, want)
+	}
+}
+
+func TestAutoGrow(t *testing.T) {
+	x, step := stepped(4)
+	x.HighWater = 0.5
+	x.AutoGrow = true
+	Start(x)
+
+	for i := 0; i < 4; i++ {
+		Sample(x, 1)
+	}
+	step <- struct{}{} // leaves 3 queued, past high water
+	for deadline := time.Now().Add(time.Second); Stats(x).Capacity == 4 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if c := Stats(x).Capacity; c != 8 {
+		t.Fatalf("capacity: got %d, want 8", c)
+	}
+	for i := 0; i < 4; i++ {
+		if err := SampleErr(x, 1); err != nil {
+			t.Fatalf("after growing: %v", err)
+		}
+	}
+	close(step)
+	if got := StopAndCollect(x); got != 8 {
+		t.Errorf("got %d, want all 8 samples", got)
+	}
+}
+
+func TestPause(t *testing.T) {
+	x := summing(4)
+	Start(x)
+	Sample(x, 1)
+	Pause(x)
+	if !Paused(x) {
+		t.Error("Paused: got false")
+	}
+	if err := SampleErr(x, 10); err != ErrGated {
+		t.Errorf("while paused: got %v, want ErrGated", err)
+	}
+	Resume(x)
+	if Paused(x) {
+		t.Error("after Resume: Paused got true")
+	}
+	Sample(x, 2)
+	if got := StopAndCollect(x); got != 3 {
+		t.Errorf("got %d, want the state kept across the pause", got)
+	}
+	if n := Gated(x); n != 1 {
+		t.Errorf("gated: got %d, want 1", n)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	x := summing(16)
+	if err := Flush(x); err != ErrNotStarted {
+		t.Errorf("before Start: got %v, want ErrNotStarted", err)
+	}
+	x.Strategy = BlockOnOverflow
+	Start(x)
+
+	// every producer sees at least its own samples processed
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 1; j <= 50; j++ {
+				Sample(x, 1)
+				if err := Flush(x); err != nil {
+					t.Error(err)
+					return
+				}
+				if got := Snapshot(x); got < j {
+					t.Errorf("after flushing %d samples: state %d", j, got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a pending coalesced run counts as pushed
+	y := SamplerMakeCoalesce(4, func(a, b int) bool { return a == b }, func(s *int, v, n int) { *s += v * n })
+	Start(y)
+	Sample(y, 2)
+	Sample(y, 2)
+	Flush(y)
+	if got := Snapshot(y); got != 4 {
+		t.Errorf("coalesced: got %d, want 4", got)
+	}
+	Stop(y)
+
+	StopAndWait(x)
+	if err := Flush(x); err != ErrInactive {
+		t.Errorf("after Stop: got %v, want ErrInactive", err)
+	}
+}
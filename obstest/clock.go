@@ -0,0 +1,89 @@
+package obstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blitz-frost/obs"
+)
+
+// A Clock is an obs.Clock whose time only moves when advanced manually.
+//
+// Its methods are concurrent safe.
+type Clock struct {
+	now     time.Time
+	tickers []*ticker
+	mux     sync.Mutex
+}
+
+func ClockMake(start time.Time) *Clock {
+	return &Clock{
+		now: start,
+	}
+}
+
+// Advance moves the Clock forward, firing any tickers that come due.
+// Like a time.Ticker, a ticker whose previous tick hasn't been received yet drops the new ones.
+func (x *Clock) Advance(d time.Duration) {
+	x.mux.Lock()
+	x.now = x.now.Add(d)
+
+	active := x.tickers[:0]
+	for _, t := range x.tickers {
+		if t.stopped {
+			continue
+		}
+		for !t.next.After(x.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+		active = append(active, t)
+	}
+	x.tickers = active
+	x.mux.Unlock()
+}
+
+func (x *Clock) NewTicker(d time.Duration) obs.Ticker {
+	if d <= 0 {
+		panic("obstest: non-positive ticker interval")
+	}
+
+	x.mux.Lock()
+	t := &ticker{
+		clock:  x,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   x.now.Add(d),
+	}
+	x.tickers = append(x.tickers, t)
+	x.mux.Unlock()
+	return t
+}
+
+func (x *Clock) Now() time.Time {
+	x.mux.Lock()
+	o := x.now
+	x.mux.Unlock()
+	return o
+}
+
+type ticker struct {
+	clock   *Clock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (x *ticker) C() <-chan time.Time {
+	return x.c
+}
+
+func (x *ticker) Stop() {
+	x.clock.mux.Lock()
+	x.stopped = true
+	x.clock.mux.Unlock()
+}
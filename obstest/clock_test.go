@@ -0,0 +1,46 @@
+package obstest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Unix(100, 0)
+	x := ClockMake(start)
+	tk := x.NewTicker(time.Second)
+
+	x.Advance(999 * time.Millisecond)
+	select {
+	case <-tk.C():
+		t.Fatal("ticked early")
+	default:
+	}
+	if got := x.Now(); !got.Equal(start.Add(999 * time.Millisecond)) {
+		t.Errorf("Now: got %v", got)
+	}
+
+	// further ticks are dropped while the first is pending
+	x.Advance(3 * time.Second)
+	if at := <-tk.C(); !at.Equal(start.Add(time.Second)) {
+		t.Errorf("tick at %v, want %v", at, start.Add(time.Second))
+	}
+	select {
+	case at := <-tk.C():
+		t.Errorf("dropped tick delivered at %v", at)
+	default:
+	}
+
+	x.Advance(time.Second)
+	if at := <-tk.C(); !at.Equal(start.Add(4 * time.Second)) {
+		t.Errorf("tick at %v, want the schedule kept", at)
+	}
+
+	tk.Stop()
+	x.Advance(time.Hour)
+	select {
+	case <-tk.C():
+		t.Error("stopped ticker ticked")
+	default:
+	}
+}
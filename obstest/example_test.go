@@ -0,0 +1,27 @@
+package obstest_test
+
+import (
+	"fmt"
+
+	"github.com/blitz-frost/obs/obstest"
+)
+
+// A Fake replaces the Sampler of the code under test, to check what it sampled without waiting on a goroutine.
+func ExampleFake() {
+	type stats struct {
+		count, bytes int
+	}
+	x := obstest.FakeMake(func(s *stats, n int) {
+		s.count++
+		s.bytes += n
+	})
+
+	x.Start()
+	for _, msg := range []string{"hello", "world!"} {
+		x.Sample(len(msg)) // as the instrumented code would
+	}
+	x.Stop()
+
+	fmt.Println(x.Samples(), x.State())
+	// Output: [5 6] {2 11}
+}
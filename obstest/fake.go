@@ -0,0 +1,78 @@
+package obstest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// A Fake stands in for an obs.Sampler, offering the same method set.
+// It processes samples synchronously, in the caller's goroutine, and records them.
+//
+// Its methods are concurrent safe.
+type Fake[S any, T any] struct {
+	state      S
+	samples    []T
+	sampleFunc func(*S, T)
+
+	active bool
+	mux    sync.Mutex
+}
+
+func FakeMake[S any, T any](sampleFunc func(*S, T)) *Fake[S, T] {
+	return &Fake[S, T]{
+		sampleFunc: sampleFunc,
+	}
+}
+
+// Sample processes and records the sample.
+// NoOp if the Fake is not started, or has been stopped.
+func (x *Fake[S, T]) Sample(v T) {
+	x.mux.Lock()
+	if x.active {
+		x.samples = append(x.samples, v)
+		x.sampleFunc(&x.state, v)
+	}
+	x.mux.Unlock()
+}
+
+// Samples returns the processed samples, in order.
+func (x *Fake[S, T]) Samples() []T {
+	x.mux.Lock()
+	o := append([]T(nil), x.samples...)
+	x.mux.Unlock()
+	return o
+}
+
+func (x *Fake[S, T]) Start() {
+	x.mux.Lock()
+	x.active = true
+	x.mux.Unlock()
+}
+
+// State returns the current aggregation state.
+func (x *Fake[S, T]) State() S {
+	x.mux.Lock()
+	o := x.state
+	x.mux.Unlock()
+	return o
+}
+
+func (x *Fake[S, T]) Stop() {
+	x.mux.Lock()
+	x.active = false
+	x.mux.Unlock()
+}
+
+// AssertSamples fails the test if the samples processed by the Fake differ from want.
+func AssertSamples[S any, T any](t testing.TB, x *Fake[S, T], want []T) {
+	t.Helper()
+
+	got := x.Samples()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("samples: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package obstest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// A failRecorder is a testing.TB that records failures instead of reporting them.
+type failRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (x *failRecorder) Errorf(format string, args ...any) {
+	x.failures = append(x.failures, fmt.Sprintf(format, args...))
+}
+
+func (x *failRecorder) Helper() {}
+
+func TestFake(t *testing.T) {
+	x := FakeMake(func(s *int, v int) { *s += v })
+	x.Sample(1) // not started
+	x.Start()
+	x.Sample(2)
+	x.Sample(3)
+	x.Stop()
+	x.Sample(4)
+
+	if got := x.State(); got != 5 {
+		t.Errorf("state: got %d, want 5", got)
+	}
+	AssertSamples(t, x, []int{2, 3})
+
+	r := &failRecorder{TB: t}
+	AssertSamples(r, x, []int{3, 2})
+	if len(r.failures) != 1 {
+		t.Errorf("mismatching samples: got failures %q", r.failures)
+	}
+}
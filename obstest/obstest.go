@@ -0,0 +1,22 @@
+// Package obstest provides utilities for testing code instrumented with obs.
+package obstest
+
+import "github.com/blitz-frost/obs"
+
+// Collect pushes the samples through a Sampler with the given queue size, and returns them in the order they were processed.
+// As with any Sampler, samples are lost if the queue overflows.
+func Collect[T any](queueSize int, samples []T) []T {
+	done := make(chan []T)
+	x := Recording[T](queueSize)
+	x.Final = func(s *[]T) {
+		done <- *s
+	}
+
+	obs.Start(x)
+	for _, v := range samples {
+		obs.Sample(x, v)
+	}
+	obs.Stop(x)
+
+	return <-done
+}
@@ -0,0 +1,16 @@
+package obstest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	want := []int{3, 1, 4, 1, 5, 9, 2, 6}
+	if got := Collect(len(want), want); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := Collect[int](1, nil); len(got) != 0 {
+		t.Errorf("no samples: got %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package obstest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blitz-frost/obs"
+)
+
+// Recording returns a Sampler whose state is the sequence of samples it processed, in processing order.
+// Its state must be copied (e.g. with obs.StopAndCollect) before inspection, as it stays in use while running.
+func Recording[T any](queueSize int) *obs.Sampler[[]T, T] {
+	x := obs.SamplerMake(queueSize, func(s *[]T, v T) {
+		*s = append(*s, v)
+	})
+	return x
+}
+
+// AssertOrder fails the test if got is not the same sequence as want, reporting the first position where they differ.
+func AssertOrder[T any](t testing.TB, got, want []T) {
+	t.Helper()
+
+	for i := 0; i < min(len(got), len(want)); i++ {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("order: position %d: got %v, want %v", i, got[i], want[i])
+			return
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("order: got %d samples, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,35 @@
+package obstest
+
+import (
+	"testing"
+
+	"github.com/blitz-frost/obs"
+)
+
+func TestAssertOrder(t *testing.T) {
+	AssertOrder(t, []int{1, 2, 3}, []int{1, 2, 3})
+	AssertOrder[int](t, nil, nil)
+
+	for _, c := range []struct {
+		got, want []int
+	}{
+		{[]int{1, 3, 2}, []int{1, 2, 3}},
+		{[]int{1, 2}, []int{1, 2, 3}},
+		{[]int{1, 2, 3}, []int{1, 2}},
+	} {
+		r := &failRecorder{TB: t}
+		AssertOrder(r, c.got, c.want)
+		if len(r.failures) != 1 {
+			t.Errorf("%v against %v: got failures %q", c.got, c.want, r.failures)
+		}
+	}
+}
+
+func TestRecording(t *testing.T) {
+	x := Recording[int](8)
+	obs.Start(x)
+	for i := 0; i < 8; i++ {
+		obs.Sample(x, i)
+	}
+	AssertOrder(t, obs.StopAndCollect(x), []int{0, 1, 2, 3, 4, 5, 6, 7})
+}
@@ -0,0 +1,89 @@
+package obs
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WriteOpenMetrics writes the numeric members of a Map in the OpenMetrics text exposition format, ending with the mandatory "# EOF" line.
+//
+// Labels are sanitized into metric names. Counters are exposed with the "_total" suffix, everything else as a gauge.
+// If a Value has a Unit, the metric name is suffixed with it, as the format requires.
+// Tags are exposed as metric labels. Values sharing a label form a single metric family, described by the first of them.
+// Non-numeric values are skipped, as are Values that are not Public.
+func WriteOpenMetrics(w io.Writer, m *Map) error {
+	return writeOpenMetrics(w, visible(metrics(m), Public))
+}
+
+func writeOpenMetrics(w io.Writer, values []Value) error {
+	var b bytes.Buffer
+	family := ""
+	for _, v := range values {
+		f, ok := loadFloat(v)
+		if !ok {
+			continue
+		}
+
+		name := sanitizeName(v.Label)
+		counter := isCounter(v)
+		if counter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		unit := sanitizeName(v.Unit)
+		if v.Unit != "" && !strings.HasSuffix(name, "_"+unit) {
+			name += "_" + unit
+		}
+
+		typ := "gauge"
+		sample := name
+		if counter {
+			typ = "counter"
+			sample += "_total"
+		}
+
+		if name != family {
+			family = name
+			b.WriteString("# TYPE " + name + " " + typ + "\n")
+			if v.Unit != "" {
+				b.WriteString("# UNIT " + name + " " + unit + "\n")
+			}
+			if v.Help != "" {
+				b.WriteString("# HELP " + name + " " + openMetricsEscaper.Replace(v.Help) + "\n")
+			}
+		}
+		b.WriteString(sample + openMetricsLabels(v.Tags) + " " + formatFloat(f) + "\n")
+	}
+	b.WriteString("# EOF\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// openMetricsLabels formats tags as an OpenMetrics label set, sorted by name.
+// Returns an empty string if there are no tags.
+func openMetricsLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sanitizeName(k) + `="` + openMetricsEscaper.Replace(tags[k]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package obs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteOpenMetricsMetadata(t *testing.T) {
+	m := MapOf(
+		Value{Label: "latency", Loader: constant(0.5), Help: `p50 "latency"`, Unit: "seconds"},
+		Value{Label: "temperature", Loader: constant(20)},
+	)
+
+	var b bytes.Buffer
+	if err := WriteOpenMetrics(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE latency_seconds gauge
+# UNIT latency_seconds seconds
+# HELP latency_seconds p50 \"latency\"
+latency_seconds 0.5
+# TYPE temperature gauge
+temperature 20
+# EOF
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var requests, errors Counter
+	requests.Add(7)
+	errors.Add(1)
+	m := MapOf(
+		Value{Label: "http.requests", Loader: &requests},
+		Value{Label: "errors_total", Loader: &errors},
+		Value{Label: "version", Loader: constant("1.2.3")},
+	)
+
+	var b bytes.Buffer
+	if err := WriteOpenMetrics(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE errors counter
+errors_total 1
+# TYPE http_requests counter
+http_requests_total 7
+# EOF
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	WriteOpenMetrics(&b, MapMake())
+	if got := b.String(); got != "# EOF\n" {
+		t.Errorf("empty Map: got %q, want only the EOF line", got)
+	}
+}
+
+func TestWriteOpenMetricsTags(t *testing.T) {
+	var c Counter
+	c.Add(2)
+	m := MapOf(
+		Value{Label: "requests", Loader: &c, Tags: map[string]string{"region": "eu", "code": "200"}},
+		Value{Label: "up", Loader: constant(1)},
+	)
+
+	var b bytes.Buffer
+	WriteOpenMetrics(&b, m)
+	want := `# TYPE requests counter
+requests_total{code="200",region="eu"} 2
+# TYPE up gauge
+up 1
+# EOF
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
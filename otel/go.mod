@@ -0,0 +1,11 @@
+module github.com/blitz-frost/obs/otel
+
+go 1.22.0
+
+require (
+	github.com/blitz-frost/obs v0.0.0
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/metric v1.35.0
+)
+
+replace github.com/blitz-frost/obs => ../
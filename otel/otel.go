@@ -0,0 +1,95 @@
+// Package otel exposes the members of an obs.Map through the OpenTelemetry metrics API, as asynchronous instruments.
+//
+// It is a separate module, so that the obs package itself doesn't depend on OpenTelemetry.
+package otel
+
+import (
+	"context"
+
+	"github.com/blitz-frost/obs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Register creates an asynchronous instrument for each numeric Public member of the Map, named after its label,
+// and registers a callback observing them on every collection.
+// Members whose Loader is Kinded as a "counter" become Float64ObservableCounters, the rest Float64ObservableGauges.
+// Help and Unit are passed on as instrument description and unit, and Tags as attributes.
+//
+// Instruments are created for the members present at the time of the call, and observed until the registration is
+// undone, even if they are deleted from the Map. To pick up changes, unregister and Register again.
+func Register(meter metric.Meter, m *obs.Map) (metric.Registration, error) {
+	var (
+		instruments []metric.Observable
+		observers   []func(metric.Observer)
+	)
+	for _, v := range m.Values() {
+		if v.Visibility != obs.Public {
+			continue
+		}
+		if _, ok := obs.LoadFloat(v); !ok {
+			continue
+		}
+
+		inst, observe, err := instrument(meter, v)
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, inst)
+		observers = append(observers, observe)
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, observe := range observers {
+			observe(o)
+		}
+		return nil
+	}, instruments...)
+}
+
+// RegisterValue is like Register, but for a single Value, regardless of its Visibility.
+func RegisterValue(meter metric.Meter, v obs.Value) (metric.Registration, error) {
+	inst, observe, err := instrument(meter, v)
+	if err != nil {
+		return nil, err
+	}
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		observe(o)
+		return nil
+	}, inst)
+}
+
+// instrument creates the instrument for a Value, along with the function observing it.
+func instrument(meter metric.Meter, v obs.Value) (metric.Observable, func(metric.Observer), error) {
+	attrs := metric.WithAttributes(attributes(v.Tags)...)
+
+	if k, ok := v.Loader.(obs.Kinded); ok && k.Kind() == "counter" {
+		inst, err := meter.Float64ObservableCounter(v.Label, metric.WithDescription(v.Help), metric.WithUnit(v.Unit))
+		if err != nil {
+			return nil, nil, err
+		}
+		return inst, func(o metric.Observer) {
+			if f, ok := obs.LoadFloat(v); ok {
+				o.ObserveFloat64(inst, f, attrs)
+			}
+		}, nil
+	}
+
+	inst, err := meter.Float64ObservableGauge(v.Label, metric.WithDescription(v.Help), metric.WithUnit(v.Unit))
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, func(o metric.Observer) {
+		if f, ok := obs.LoadFloat(v); ok {
+			o.ObserveFloat64(inst, f, attrs)
+		}
+	}, nil
+}
+
+func attributes(tags map[string]string) []attribute.KeyValue {
+	o := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		o = append(o, attribute.String(k, v))
+	}
+	return o
+}
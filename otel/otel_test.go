@@ -0,0 +1,126 @@
+package otel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/blitz-frost/obs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// fakeMeter records the instruments created through it, and runs the registered callbacks on collect.
+type fakeMeter struct {
+	noop.Meter
+	instruments map[string]string // kind, description and unit by name
+	callbacks   []metric.Callback
+}
+
+type fakeCounter struct {
+	noop.Float64ObservableCounter
+	name string
+}
+
+type fakeGauge struct {
+	noop.Float64ObservableGauge
+	name string
+}
+
+func (x *fakeMeter) Float64ObservableCounter(name string, opts ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	c := metric.NewFloat64ObservableCounterConfig(opts...)
+	x.instruments[name] = "counter " + c.Description() + " " + c.Unit()
+	return fakeCounter{name: name}, nil
+}
+
+func (x *fakeMeter) Float64ObservableGauge(name string, opts ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	c := metric.NewFloat64ObservableGaugeConfig(opts...)
+	x.instruments[name] = "gauge " + c.Description() + " " + c.Unit()
+	return fakeGauge{name: name}, nil
+}
+
+func (x *fakeMeter) RegisterCallback(f metric.Callback, _ ...metric.Observable) (metric.Registration, error) {
+	x.callbacks = append(x.callbacks, f)
+	return noop.Registration{}, nil
+}
+
+// collect returns the observations of all callbacks, keyed by instrument name.
+func (x *fakeMeter) collect() map[string]observation {
+	o := fakeObserver{observed: make(map[string]observation)}
+	for _, f := range x.callbacks {
+		f(context.Background(), o)
+	}
+	return o.observed
+}
+
+type observation struct {
+	value float64
+	attrs attribute.Set
+}
+
+type fakeObserver struct {
+	noop.Observer
+	observed map[string]observation
+}
+
+func (x fakeObserver) ObserveFloat64(inst metric.Float64Observable, v float64, opts ...metric.ObserveOption) {
+	var name string
+	switch inst := inst.(type) {
+	case fakeCounter:
+		name = inst.name
+	case fakeGauge:
+		name = inst.name
+	}
+	x.observed[name] = observation{v, metric.NewObserveConfig(opts).Attributes()}
+}
+
+func TestRegister(t *testing.T) {
+	var requests obs.Counter
+	requests.Add(3)
+	temperature := 21.5
+	m := obs.MapOf(
+		obs.Value{Label: "requests", Loader: &requests, Help: "requests served", Tags: map[string]string{"code": "200"}},
+		obs.Value{Label: "temperature", Loader: obs.LoaderFunc[float64](func() float64 { return temperature }), Unit: "C"},
+		obs.Value{Label: "version", Loader: obs.LoaderFunc[string](func() string { return "v1" })},
+		obs.Value{Label: "internal", Loader: obs.LoaderFunc[int](func() int { return 1 }), Visibility: obs.Debug},
+	)
+
+	meter := &fakeMeter{instruments: make(map[string]string)}
+	if _, err := Register(meter, m); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"requests":    "counter requests served ",
+		"temperature": "gauge  C",
+	}
+	if !reflect.DeepEqual(meter.instruments, want) {
+		t.Errorf("instruments: got %q, want %q", meter.instruments, want)
+	}
+
+	// values are observed afresh on every collection
+	requests.Add(2)
+	temperature = 23
+	got := meter.collect()
+	attrs := attribute.NewSet(attribute.String("code", "200"))
+	if o := got["requests"]; o.value != 5 || !o.attrs.Equals(&attrs) {
+		t.Errorf("requests: got %v %v", o.value, o.attrs.Encoded(attribute.DefaultEncoder()))
+	}
+	if o := got["temperature"]; o.value != 23 || o.attrs.Len() != 0 {
+		t.Errorf("temperature: got %+v", o)
+	}
+	if len(got) != 2 {
+		t.Errorf("got %d observations, want 2", len(got))
+	}
+}
+
+func TestRegisterValue(t *testing.T) {
+	meter := &fakeMeter{instruments: make(map[string]string)}
+	v := obs.Value{Label: "internal", Loader: obs.LoaderFunc[int](func() int { return 7 }), Visibility: obs.Debug}
+	if _, err := RegisterValue(meter, v); err != nil {
+		t.Fatal(err)
+	}
+	if o := meter.collect()["internal"]; o.value != 7 {
+		t.Errorf("got %v, want 7 regardless of Visibility", o.value)
+	}
+}
@@ -0,0 +1,114 @@
+package obs
+
+// An OverflowAction is what becomes of a sample that finds the queue full.
+type OverflowAction int
+
+const (
+	OverflowBlock      OverflowAction = iota // wait for room in the queue
+	OverflowDrop                             // discard the sample, counting it as dropped
+	OverflowGrow                             // double the queue size, up to MaxQueueSize, then drop if that fails
+	OverflowShutdown                         // discard the sample, and shut the Sampler down as on a default overflow
+	OverflowDropOldest                       // discard the oldest queued sample instead, counting it as dropped, to make room
+)
+
+// An OverflowStrategy decides what happens when a sample finds the queue full.
+// It is consulted by the producer, after it failed to queue the sample, with the Sampler's current stats.
+// Implementations must be concurrent safe, as producers may consult them at the same time.
+//
+// For example, a strategy that sheds load while waiting out short bursts:
+//
+//	obs.OverflowFunc(func(s obs.SamplerStats) obs.OverflowAction {
+//		if s.Dropped > 1000 {
+//			return obs.OverflowDrop
+//		}
+//		return obs.OverflowBlock
+//	})
+type OverflowStrategy interface {
+	Full(SamplerStats) OverflowAction
+}
+
+// OverflowFunc adapts a function to the OverflowStrategy interface.
+type OverflowFunc func(SamplerStats) OverflowAction
+
+func (x OverflowFunc) Full(s SamplerStats) OverflowAction {
+	return x(s)
+}
+
+// Built-in strategies, always taking the same action.
+var (
+	BlockOnOverflow      OverflowStrategy = constantStrategy(OverflowBlock)
+	DropOnOverflow       OverflowStrategy = constantStrategy(OverflowDrop)
+	DropOldestOnOverflow OverflowStrategy = constantStrategy(OverflowDropOldest)
+	GrowOnOverflow       OverflowStrategy = constantStrategy(OverflowGrow)
+	ShutdownOnOverflow   OverflowStrategy = constantStrategy(OverflowShutdown)
+)
+
+type constantStrategy OverflowAction
+
+func (x constantStrategy) Full(SamplerStats) OverflowAction {
+	return OverflowAction(x)
+}
+
+// pushStrategy queues an item on a Sampler with an OverflowStrategy.
+func pushStrategy[S any, T any](x *Sampler[S, T], it item[T]) error {
+	for !trySend(x, it) {
+		if x.closed.Load() {
+			return reject(x, it)
+		}
+
+		switch x.Strategy.Full(stats(x)) {
+		case OverflowBlock:
+			if !send(x, it) {
+				return reject(x, it)
+			}
+			return nil
+		case OverflowDropOldest:
+			evictOldest(x)
+			continue
+		case OverflowGrow:
+			// retry if the queue grew, here or by another producer; once at MaxQueueSize, drop
+			size := cap(queue(x))
+			if size < MaxQueueSize && (growTo(x, min(2*size, MaxQueueSize)) || cap(queue(x)) > size) {
+				continue
+			}
+		case OverflowShutdown:
+			dropItem(x, it)
+			it.done()
+			x.overflowed.Store(true)
+			if deactivate(x) && x.Overflow != nil {
+				x.Overflow()
+			}
+			return ErrInactive
+		}
+
+		dropItem(x, it)
+		it.done()
+		return ErrOverloaded
+	}
+	return nil
+}
+
+// evictOldest discards the oldest sample in the queue, if any.
+func evictOldest[S any, T any](x *Sampler[S, T]) {
+	x.queueMux.RLock()
+	defer x.queueMux.RUnlock()
+
+	if x.closed.Load() {
+		return
+	}
+	select {
+	case it := <-*x.sampleChan.Load():
+		if it.flush {
+			// Flush markers are not samples; move them to the back, where they wait for a superset of what they did
+			select {
+			case *x.sampleChan.Load() <- it:
+				return
+			default:
+			}
+		} else {
+			dropItem(x, it)
+		}
+		it.done()
+	default:
+	}
+}
@@ -0,0 +1,98 @@
+package obs
+
+import (
+	"testing"
+)
+
+func TestGrowOnOverflowClamped(t *testing.T) {
+	busy := make(chan struct{})
+	release := make(chan struct{})
+	x := SamplerMake(MaxQueueSize/2+1, func(s *int, v int) {
+		if v == 0 {
+			close(busy)
+			<-release
+		}
+	})
+	x.Strategy = GrowOnOverflow
+	Start(x)
+
+	Sample(x, 0)
+	<-busy
+	// the replaced queue is drained before the new one, so it adds to the capacity
+	n := MaxQueueSize/2 + 1 + MaxQueueSize
+	for i := 1; i <= n+1; i++ {
+		SampleErr(x, i)
+	}
+
+	if got := cap(queue(x)); got != MaxQueueSize {
+		t.Errorf("queue grew to %d, want MaxQueueSize (%d)", got, MaxQueueSize)
+	}
+	if got := Dropped(x); got != 1 {
+		t.Errorf("dropped %d samples past MaxQueueSize, want 1", got)
+	}
+	close(release)
+	StopAndWait(x)
+}
+
+func TestOverflowStrategies(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		strategy OverflowStrategy
+		err      error
+		want     int
+	}{
+		{"drop", DropOnOverflow, ErrOverloaded, 1 + 2},
+		{"drop oldest", DropOldestOnOverflow, nil, 2 + 3},
+	} {
+		x, busy, release := blocked(2)
+		x.Strategy = c.strategy
+		Start(x)
+		Sample(x, 0)
+		<-busy
+		Sample(x, 1)
+		Sample(x, 2)
+		if err := SampleErr(x, 3); err != c.err {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+		s := Stats(x)
+		if s.Queued != 2 || s.Capacity != 2 || s.Dropped != 1 {
+			t.Errorf("%s: got stats %+v", c.name, s)
+		}
+		close(release)
+		if got := StopAndCollect(x); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOverflowFunc(t *testing.T) {
+	x, busy, release := blocked(1)
+	var seen []SamplerStats
+	x.Strategy = OverflowFunc(func(s SamplerStats) OverflowAction {
+		seen = append(seen, s)
+		if s.Dropped > 0 {
+			return OverflowShutdown
+		}
+		return OverflowDrop
+	})
+	overflowed := false
+	x.Overflow = func() { overflowed = true }
+	Start(x)
+	Sample(x, 0)
+	<-busy
+	Sample(x, 1)
+	if err := SampleErr(x, 2); err != ErrOverloaded {
+		t.Errorf("first overflow: got %v, want ErrOverloaded", err)
+	}
+	if err := SampleErr(x, 3); err != ErrInactive {
+		t.Errorf("second overflow: got %v, want ErrInactive", err)
+	}
+	if !overflowed {
+		t.Error("Overflow not called on shutdown")
+	}
+	if len(seen) != 2 || seen[0].Dropped != 0 || seen[1].Dropped != 1 {
+		t.Errorf("strategy consulted with %+v", seen)
+	}
+	close(release)
+	StopAndWait(x)
+}
@@ -0,0 +1,55 @@
+package obs
+
+import "time"
+
+// StageMake returns a Sampler acting as a stage of a pipeline: its sample function may emit any number of values
+// derived from each sample to next, typically the Sampler of the following stage, which processes them in its own
+// goroutine, from its own queue. Callbacks such as OnTick and Final can emit as well, by sampling next directly.
+//
+// For example, raw events aggregated per second, then exported:
+//
+//	export := obs.SamplerMake(16, exportFunc)
+//	perSecond := obs.StageMake(1024, export, func(s *Counts, e Event, emit func(Counts)) {
+//		s.Add(e)
+//	})
+//	perSecond.TickInterval = time.Second
+//	perSecond.OnTick = func(s *Counts) {
+//		export.Sample(*s)
+//		*s = Counts{}
+//	}
+//
+//	p := obs.Pipeline{perSecond, export}
+//	p.Start()
+//	defer p.Stop()
+func StageMake[S any, T any, U any](queueSize int, next Consumer[U], sampleFunc func(s *S, v T, emit func(U))) *Sampler[S, T] {
+	emit := next.Sample
+	return SamplerMake(queueSize, func(s *S, v T) {
+		sampleFunc(s, v, emit)
+	})
+}
+
+// A Pipeline coordinates the lifecycles of a chain of stages, ordered from the first (most upstream) to the last.
+type Pipeline []AnySampler
+
+// Start starts the stages from last to first, so that every stage's consumer is running before it can emit.
+func (x Pipeline) Start() {
+	for i := len(x) - 1; i >= 0; i-- {
+		x[i].Start()
+	}
+}
+
+// Stop stops the stages from first to last, waiting for each to finish processing (including Final) before
+// stopping the next, so that nothing emitted on the way is lost.
+func (x Pipeline) Stop() {
+	for _, v := range x {
+		v.Stop()
+		if d, ok := v.(interface{ Done() <-chan struct{} }); ok {
+			<-d.Done()
+		}
+	}
+}
+
+// StopTimeout is like Stop, but gives up after d, as by StopAllOrdered.
+func (x Pipeline) StopTimeout(d time.Duration) error {
+	return StopAllOrdered(x, d)
+}
@@ -0,0 +1,47 @@
+package obs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	words := SamplerMake(4, func(s *[]string, v string) { *s = append(*s, v) })
+	words.Strategy = BlockOnOverflow
+	lines := StageMake(4, words, func(s *int, v string, emit func(string)) {
+		*s++
+		for _, w := range strings.Fields(v) {
+			emit(w)
+		}
+	})
+	lines.Final = func(s *int) { words.Sample("lines:" + fmt.Sprint(*s)) } // emitted on the way down
+
+	p := Pipeline{lines, words}
+	p.Start()
+	Sample(lines, "a b")
+	Sample(lines, "c d e")
+	p.Stop()
+
+	got := Snapshot(words)
+	if want := []string{"a", "b", "c", "d", "e", "lines:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPipelineStopTimeout(t *testing.T) {
+	last, busy, release := blocked(4)
+	first := StageMake(4, last, func(s *int, v int, emit func(int)) { emit(v) })
+	p := Pipeline{first, last}
+	p.Start()
+	Sample(first, 0)
+	<-busy
+	if err := p.StopTimeout(10 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+	close(release)
+	<-last.Done()
+}
@@ -0,0 +1,174 @@
+package obs
+
+import "sync"
+
+// A Pool processes samples like a Sampler, but with several goroutines, each aggregating into its own partial state.
+// Partial states are merged using a combine function when the aggregate is needed.
+//
+// Samples are processed concurrently and in no particular order, making Pools only suitable for
+// commutative aggregations (e.g. sums, counts, min/max), where throughput matters more than ordering.
+//
+// Unlike a Sampler, samples are never blocked on: if the queue is full, the Pool shuts down as on overflow.
+// Its methods are concurrent safe.
+type Pool[S any, T any] struct {
+	Final    func(*S) // called with the combined state when the last sample has been processed, if non-nil
+	Overflow func()   // called when a queue overflow occurs, if non-nil
+
+	workers []*poolWorker[S]
+
+	sampleChan chan T
+	sampleFunc func(*S, T)
+	combine    func(dst *S, src S)
+
+	started bool
+	closed  bool
+	mux     sync.RWMutex // write locked to change lifecycle state
+	wg      sync.WaitGroup
+	done    chan struct{} // closed once stopped and drained, after Final
+}
+
+type poolWorker[S any] struct {
+	state S
+	mux   sync.Mutex
+}
+
+func SamplerMakePool[S any, T any](queueSize, workers int, sampleFunc func(*S, T), combine func(dst *S, src S)) *Pool[S, T] {
+	if workers < 1 {
+		workers = 1
+	}
+
+	x := &Pool[S, T]{
+		workers:    make([]*poolWorker[S], workers),
+		sampleChan: make(chan T, queueSize),
+		sampleFunc: sampleFunc,
+		combine:    combine,
+		done:       make(chan struct{}),
+	}
+	for i := range x.workers {
+		x.workers[i] = &poolWorker[S]{}
+	}
+	return x
+}
+
+// Load returns the combined state, as an S.
+func (x *Pool[S, T]) Load() any {
+	return MergedSnapshot(x)
+}
+
+// MergedSnapshot returns the combination of snapshots of all partial states, which are shallow unless the state is a Cloner.
+// Safe to use while the Pool is running, in which case the result is only approximate: the partial states are
+// captured one after the other, while the other workers keep processing, and samples still queued are not accounted for.
+func MergedSnapshot[S any, T any](x *Pool[S, T]) S {
+	return x.merged()
+}
+
+// Sample pushes a new sample for the Pool to process.
+// NoOp if the Pool is not started, closed or has overflowed.
+func (x *Pool[S, T]) Sample(v T) {
+	x.mux.RLock()
+	if !x.started || x.closed {
+		x.mux.RUnlock()
+		return
+	}
+
+	select {
+	case x.sampleChan <- v:
+		x.mux.RUnlock()
+	default:
+		x.mux.RUnlock()
+		if x.shutdown() && x.Overflow != nil {
+			x.Overflow()
+		}
+	}
+}
+
+// Start launches the processing goroutines.
+// NoOp if the Pool has already been started or stopped.
+func (x *Pool[S, T]) Start() {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if x.started || x.closed {
+		return
+	}
+	x.started = true
+
+	x.wg.Add(len(x.workers))
+	for _, w := range x.workers {
+		go x.loop(w)
+	}
+
+	go func() {
+		defer close(x.done)
+		x.wg.Wait()
+		if x.Final != nil {
+			o := x.merged()
+			x.Final(&o)
+		}
+	}()
+}
+
+// Done returns a channel that is closed once the Pool has been stopped, and has finished processing and returned from Final.
+func (x *Pool[S, T]) Done() <-chan struct{} {
+	return x.done
+}
+
+// Stop terminates the processing goroutines, once they have processed the remaining samples.
+// Must be called when the Pool is no longer needed.
+// Subsequent calls are NoOps.
+func (x *Pool[S, T]) Stop() {
+	x.shutdown()
+}
+
+func (x *Pool[S, T]) loop(w *poolWorker[S]) {
+	defer x.wg.Done()
+
+	for sample := range x.sampleChan {
+		w.mux.Lock()
+		x.sampleFunc(&w.state, sample)
+		w.mux.Unlock()
+	}
+}
+
+// SnapshotReset is like MergedSnapshot, but also resets each partial state as it is captured, as ResetState would for a Sampler.
+// Returns an S.
+func (x *Pool[S, T]) SnapshotReset() any {
+	return x.snapshot(true)
+}
+
+// merged returns the combination of all partial states.
+func (x *Pool[S, T]) merged() S {
+	return x.snapshot(false)
+}
+
+// snapshot returns the combination of all partial states, optionally resetting them.
+func (x *Pool[S, T]) snapshot(reset bool) S {
+	var o S
+	for _, w := range x.workers {
+		w.mux.Lock()
+		partial := copyState(&w.state)
+		if reset {
+			resetState(&w.state)
+		}
+		w.mux.Unlock()
+
+		x.combine(&o, partial)
+	}
+	return o
+}
+
+// shutdown closes the queue. Returns false if it was already closed.
+func (x *Pool[S, T]) shutdown() bool {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if x.closed {
+		return false
+	}
+	x.closed = true
+	close(x.sampleChan)
+	if !x.started {
+		close(x.done)
+	}
+	return true
+}
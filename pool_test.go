@@ -0,0 +1,64 @@
+package obs
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	var final int
+	x := SamplerMakePool(1000, 4, func(s *int, v int) { *s += v }, func(dst *int, src int) { *dst += src })
+	x.Final = func(s *int) { final = *s }
+	x.Start()
+
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := p*250 + 1; i <= (p+1)*250; i++ {
+				x.Sample(i)
+			}
+		}(p)
+	}
+	wg.Wait()
+	x.Stop()
+	<-x.Done()
+
+	if got := x.Load(); got != 500500 || final != 500500 {
+		t.Errorf("merged %v, Final saw %d; want 500500", got, final)
+	}
+}
+
+func TestMergedSnapshot(t *testing.T) {
+	x := SamplerMakePool(1000, 4, func(s *int, v int) { *s += v }, func(dst *int, src int) { *dst += src })
+	x.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			x.Sample(1)
+		}
+	}()
+
+	prev := 0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		got := MergedSnapshot(x)
+		if got < prev || got > 1000 {
+			t.Fatalf("merged %d after %d", got, prev)
+		}
+		prev = got
+	}
+
+	x.Stop()
+	<-x.Done()
+	if got := MergedSnapshot(x); got != 1000 {
+		t.Errorf("once drained: got %d, want 1000", got)
+	}
+}
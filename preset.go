@@ -0,0 +1,17 @@
+package obs
+
+// Shorthands for common Sampler instantiations.
+type (
+	IntSampler   = Sampler[int, int]
+	FloatSampler = Sampler[float64, float64]
+)
+
+// IntCounterSampler returns a Sampler that sums its samples.
+func IntCounterSampler(queueSize int) *IntSampler {
+	return SamplerMake(queueSize, Sum[int])
+}
+
+// FloatGaugeSampler returns a Sampler that retains its last sample.
+func FloatGaugeSampler(queueSize int) *FloatSampler {
+	return SamplerMake(queueSize, Last[float64])
+}
@@ -0,0 +1,23 @@
+package obs
+
+import (
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	c := IntCounterSampler(4)
+	Start(c)
+	Sample(c, 2)
+	Sample(c, 3)
+	if got := StopAndCollect(c); got != 5 {
+		t.Errorf("counter: got %d, want the sum 5", got)
+	}
+
+	g := FloatGaugeSampler(4)
+	Start(g)
+	Sample(g, 2.5)
+	Sample(g, -1)
+	if got := StopAndCollect(g); got != -1 {
+		t.Errorf("gauge: got %v, want the last value -1", got)
+	}
+}
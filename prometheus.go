@@ -0,0 +1,91 @@
+package obs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// WritePrometheus writes the numeric members of a Map in the Prometheus text exposition format (version 0.0.4).
+//
+// Metric names are derived as by WriteOpenMetrics, so that both formats expose the same series.
+// Tags are exposed as metric labels. Values sharing a label form a single metric family, described by the first of them.
+// Non-numeric values are skipped, as are Values that are not Public.
+func WritePrometheus(w io.Writer, m *Map) error {
+	return writePrometheus(w, visible(metrics(m), Public))
+}
+
+func writePrometheus(w io.Writer, values []Value) error {
+	return writePrometheusNamed(w, values, sanitizeName)
+}
+
+// writePrometheusNamed is writePrometheus with metric names derived from labels by the given function.
+func writePrometheusNamed(w io.Writer, values []Value, metricName func(string) string) error {
+	var b bytes.Buffer
+	family := ""
+	for _, v := range values {
+		f, ok := loadFloat(v)
+		if !ok {
+			continue
+		}
+
+		name := metricName(v.Label)
+		counter := isCounter(v)
+		if counter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		if v.Unit != "" && !strings.HasSuffix(name, "_"+sanitizeName(v.Unit)) {
+			name += "_" + sanitizeName(v.Unit)
+		}
+
+		typ := "gauge"
+		if counter {
+			typ = "counter"
+			name += "_total"
+		}
+
+		if name != family {
+			family = name
+			if v.Help != "" {
+				b.WriteString("# HELP " + name + " " + prometheusHelpEscaper.Replace(v.Help) + "\n")
+			}
+			b.WriteString("# TYPE " + name + " " + typ + "\n")
+		}
+		b.WriteString(name + openMetricsLabels(v.Tags) + " " + formatFloat(f) + "\n")
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// A PrometheusHandler serves the Public members of a Map in the Prometheus text exposition format, for scraping.
+// Tags are exposed as metric labels.
+type PrometheusHandler struct {
+	// Name derives metric names from labels, before the usual unit and counter suffixes are appended.
+	// Its output is used verbatim, so it must produce valid metric names.
+	// Labels are sanitized as by WritePrometheus if nil.
+	Name func(label string) string
+
+	m *Map
+}
+
+func PrometheusHandlerMake(m *Map) *PrometheusHandler {
+	return &PrometheusHandler{
+		m: m,
+	}
+}
+
+func (x *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := x.Name
+	if name == nil {
+		name = sanitizeName
+	}
+
+	w.Header().Set("Content-Type", exportFormats["prometheus"].contentType)
+	if err := writePrometheusNamed(w, visible(metrics(x.m), Public), name); err != nil {
+		warn("obs: export failed", "format", "prometheus", "err", err)
+	}
+}
@@ -0,0 +1,88 @@
+package obs
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheusMetadata(t *testing.T) {
+	var c Counter
+	c.Add(3)
+	m := MapOf(
+		Value{Label: "latency", Loader: constant(0.5), Help: "request latency\nin seconds", Unit: "seconds"},
+		Value{Label: "requests", Loader: &c, Help: "requests served"},
+		Value{Label: "temperature", Loader: constant(20)},
+	)
+
+	var b bytes.Buffer
+	if err := WritePrometheus(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP latency_seconds request latency\nin seconds
+# TYPE latency_seconds gauge
+latency_seconds 0.5
+# HELP requests_total requests served
+# TYPE requests_total counter
+requests_total 3
+# TYPE temperature gauge
+temperature 20
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWritePrometheusTags(t *testing.T) {
+	m := MapOf(
+		Value{Label: "requests", Loader: constant(1), Tags: map[string]string{"path": `/a"b`, "code": "200"}},
+		Value{Label: "up", Loader: constant(1), Tags: map[string]string{}},
+	)
+
+	var b bytes.Buffer
+	WritePrometheus(&b, m)
+	want := `# TYPE requests gauge
+requests{code="200",path="/a\"b"} 1
+# TYPE up gauge
+up 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	m := MapOf(
+		Value{Label: "http.requests-total", Loader: constant(3), Tags: map[string]string{"code": "200"}},
+		Value{Label: "internal", Loader: constant(1), Visibility: Debug},
+	)
+	x := PrometheusHandlerMake(m)
+	srv := httptest.NewServer(x)
+	defer srv.Close()
+
+	get := func() (string, string) {
+		t.Helper()
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.Header.Get("Content-Type"), string(body)
+	}
+
+	contentType, body := get()
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("content type: got %q", contentType)
+	}
+	if want := "# TYPE http_requests_total gauge\nhttp_requests_total{code=\"200\"} 3\n"; body != want {
+		t.Errorf("sanitized: got %q, want %q", body, want)
+	}
+
+	x.Name = func(label string) string { return "svc_" + strings.NewReplacer(".", "_", "-", "_").Replace(label) }
+	if _, body := get(); !strings.Contains(body, "svc_http_requests_total{code=\"200\"} 3\n") {
+		t.Errorf("custom names: got %q", body)
+	}
+}
@@ -0,0 +1,51 @@
+package obs
+
+import "sync/atomic"
+
+// A Quota bounds the total number of samples queued across all the Samplers sharing it,
+// limiting their aggregate memory use when individual queue sizes don't.
+//
+// Its methods are concurrent safe.
+type Quota struct {
+	limit   int64
+	used    atomic.Int64
+	dropped atomic.Uint64
+}
+
+// QuotaMake returns a Quota allowing at most maxItems samples to be queued at once.
+func QuotaMake(maxItems int) *Quota {
+	return &Quota{
+		limit: int64(maxItems),
+	}
+}
+
+// Dropped returns the number of samples discarded because the Quota was exhausted.
+func (x *Quota) Dropped() uint64 {
+	return x.dropped.Load()
+}
+
+// Used returns the number of samples currently holding a slot.
+func (x *Quota) Used() int {
+	return int(x.used.Load())
+}
+
+// acquire takes a slot, if one is free.
+func (x *Quota) acquire() bool {
+	for {
+		n := x.used.Load()
+		if n >= x.limit {
+			x.dropped.Add(1)
+			return false
+		}
+		if x.used.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// release frees a slot. NoOp on a nil Quota.
+func (x *Quota) release() {
+	if x != nil {
+		x.used.Add(-1)
+	}
+}
@@ -0,0 +1,54 @@
+package obs
+
+import (
+	"testing"
+)
+
+func TestQuotaShared(t *testing.T) {
+	q := QuotaMake(3)
+	a, stepA := stepped(10)
+	b, stepB := stepped(10)
+	a.Quota = q
+	b.Quota = q
+	Start(a)
+	Start(b)
+
+	for _, err := range []error{SampleErr(a, 1), SampleErr(a, 1), SampleErr(b, 1)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SampleErr(b, 1); err != ErrQuota {
+		t.Errorf("over quota: got %v, want ErrQuota", err)
+	}
+	if err := SampleErr(a, 1); err != ErrQuota {
+		t.Errorf("over quota: got %v, want ErrQuota", err)
+	}
+	if n := q.Used(); n != 3 {
+		t.Errorf("used: got %d, want 3", n)
+	}
+	if n := q.Dropped(); n != 2 {
+		t.Errorf("dropped: got %d, want 2", n)
+	}
+
+	close(stepA)
+	close(stepB)
+	if got := StopAndCollect(a); got != 2 {
+		t.Errorf("a: got %d, want 2", got)
+	}
+	if got := StopAndCollect(b); got != 1 {
+		t.Errorf("b: got %d, want 1", got)
+	}
+	if n := q.Used(); n != 0 {
+		t.Errorf("used after draining: got %d, want 0", n)
+	}
+
+	// freed slots are usable again
+	c := summing(1)
+	c.Quota = q
+	Start(c)
+	if err := SampleErr(c, 5); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+	StopAndCollect(c)
+}
@@ -0,0 +1,69 @@
+package obs
+
+import (
+	"sync"
+	"time"
+)
+
+// A RateSampler measures an event rate, per second, over a sliding window.
+// The window is split into equal sub-windows, expiring one at a time as time advances.
+//
+// Its methods are concurrent safe.
+type RateSampler struct {
+	Clock Clock // time source for bucketing; the real clock if nil
+
+	ring bucketRing[int64]
+	mux  sync.Mutex
+}
+
+// RateSamplerMake returns a RateSampler over the given window, made up of n sub-windows.
+// More sub-windows make the window slide more smoothly, at the cost of memory.
+// A RateSampler over a window that isn't positive, like the zero value, counts nothing and reports no rate.
+func RateSamplerMake(window time.Duration, n int) *RateSampler {
+	return &RateSampler{
+		ring: bucketRingMake[int64](window, n),
+	}
+}
+
+func (x *RateSampler) Add(delta int64) {
+	if !x.ring.valid() {
+		return
+	}
+	epoch := x.ring.epoch(x.Clock)
+
+	x.mux.Lock()
+	*x.ring.at(epoch) += delta
+	x.mux.Unlock()
+}
+
+func (x *RateSampler) Inc() {
+	x.Add(1)
+}
+
+// AsFloat64 returns the events per second over the last window.
+// Returns 0 and false if the window isn't positive.
+func (x *RateSampler) AsFloat64() (float64, bool) {
+	if !x.ring.valid() {
+		return 0, false
+	}
+	epoch := x.ring.epoch(x.Clock)
+
+	var sum int64
+	x.mux.Lock()
+	x.ring.each(epoch, func(count *int64) {
+		sum += *count
+	})
+	x.mux.Unlock()
+
+	return float64(sum) / x.ring.window.Seconds(), true
+}
+
+func (x *RateSampler) Kind() string {
+	return "gauge"
+}
+
+// Load returns the events per second over the last window, as a float64.
+func (x *RateSampler) Load() any {
+	f, _ := x.AsFloat64()
+	return f
+}
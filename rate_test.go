@@ -0,0 +1,125 @@
+package obs_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestRateSampler(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.RateSamplerMake(10*time.Second, 10)
+	x.Clock = clock
+
+	for i := 0; i < 20; i++ {
+		x.Inc()
+	}
+	if f, ok := x.AsFloat64(); !ok || f != 2 {
+		t.Errorf("got %v, %v; want 2 per second", f, ok)
+	}
+
+	clock.Advance(5 * time.Second)
+	x.Add(10)
+	if f, _ := x.AsFloat64(); f != 3 {
+		t.Errorf("within window: got %v, want 3", f)
+	}
+
+	clock.Advance(5 * time.Second)
+	if f, _ := x.AsFloat64(); f != 1 {
+		t.Errorf("first samples expired: got %v, want 1", f)
+	}
+
+	clock.Advance(time.Minute)
+	if f, _ := x.AsFloat64(); f != 0 {
+		t.Errorf("all expired: got %v, want 0", f)
+	}
+}
+
+func TestRateSamplerZeroWindow(t *testing.T) {
+	for name, x := range map[string]*obs.RateSampler{
+		"zero value":  {},
+		"zero window": obs.RateSamplerMake(0, 4),
+	} {
+		x.Inc()
+		if f, ok := x.AsFloat64(); ok || f != 0 {
+			t.Errorf("%s: got %v, %v; want 0, false", name, f, ok)
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.SlidingWindowMake[int](10*time.Second, 10)
+	x.Clock = clock
+
+	x.Add(4)
+	x.Add(2)
+	clock.Advance(5 * time.Second)
+	x.Add(6)
+
+	s := x.Stats()
+	if s.Count != 3 || s.Sum != 12 || s.Min != 2 || s.Max != 6 || s.Mean != 4 || s.Rate != 0.3 {
+		t.Errorf("got %+v", s)
+	}
+
+	clock.Advance(5 * time.Second)
+	if s := x.Stats(); s.Count != 1 || s.Min != 6 {
+		t.Errorf("first samples expired: got %+v", s)
+	}
+}
+
+func TestSlidingWindowZeroWindow(t *testing.T) {
+	for name, x := range map[string]*obs.SlidingWindow[float64]{
+		"zero value":  {},
+		"zero window": obs.SlidingWindowMake[float64](0, 4),
+	} {
+		x.Add(1)
+		if s := x.Stats(); s != (obs.WindowStats[float64]{}) {
+			t.Errorf("%s: got %+v, want zero Stats", name, s)
+		}
+	}
+}
+
+func TestSlidingWindowSampler(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.SlidingWindowSampler[float64](8, 4*time.Second, 4)
+	x.OnStart = func(s *obs.SlidingWindow[float64]) { s.Clock = clock }
+	obs.Start(x)
+	defer obs.Stop(x)
+
+	obs.Sample(x, 1.5)
+	obs.Sample(x, 2.5)
+	obs.Flush(x)
+	data, err := json.Marshal(x.Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"Count":2,"Sum":4,"Min":1.5,"Max":2.5,"Mean":2,"Rate":0.5}`; got != want {
+		t.Errorf("Load: got %s, want %s", got, want)
+	}
+
+	// snapshots are independent of the live window
+	snap := obs.Snapshot(x)
+	obs.Sample(x, 9)
+	obs.Flush(x)
+	if s := snap.Stats(); s.Count != 2 {
+		t.Errorf("snapshot: got %+v", s)
+	}
+
+	clock.Advance(4 * time.Second)
+	snap = obs.Snapshot(x)
+	if s := snap.Stats(); s.Count != 0 {
+		t.Errorf("after the window: got %+v", s)
+	}
+
+	obs.Sample(x, 3)
+	obs.Flush(x)
+	obs.ResetState(x)
+	snap = obs.Snapshot(x)
+	if s := snap.Stats(); s.Count != 0 {
+		t.Errorf("after ResetState: got %+v", s)
+	}
+}
@@ -0,0 +1,65 @@
+package obs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// A Recorder is a flight recorder, retaining the most recent samples along with the time they were recorded,
+// for dumping the events leading up to a failure. Being a Consumer, it can be fed alongside Samplers by a Tee.
+// Being a Loader, it can be included in a Map, loading as its Events.
+//
+// Its methods are concurrent safe.
+type Recorder[T any] struct {
+	Clock Clock // time source for event times; the real clock if nil
+
+	events ring[Event[T]]
+	mux    sync.Mutex
+}
+
+// An Event is a sample recorded by a Recorder.
+type Event[T any] struct {
+	Time  time.Time
+	Value T
+}
+
+// RecorderMake returns a Recorder retaining the last n samples.
+func RecorderMake[T any](n int) *Recorder[T] {
+	return &Recorder[T]{
+		events: ringMake[Event[T]](n),
+	}
+}
+
+// Dump writes the retained events to w as JSON, one per line, oldest first.
+func (x *Recorder[T]) Dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range x.Events() {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events returns a copy of the retained events, oldest first.
+func (x *Recorder[T]) Events() []Event[T] {
+	x.mux.Lock()
+	o := x.events.slice()
+	x.mux.Unlock()
+	return o
+}
+
+// Load returns the retained events, as an []Event[T].
+func (x *Recorder[T]) Load() any {
+	return x.Events()
+}
+
+// Sample records a sample, evicting the oldest one if full.
+func (x *Recorder[T]) Sample(v T) {
+	now := clockOr(x.Clock).Now()
+	x.mux.Lock()
+	x.events.push(Event[T]{now, v})
+	x.mux.Unlock()
+}
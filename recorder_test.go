@@ -0,0 +1,51 @@
+package obs_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	clock := obstest.ClockMake(start)
+	x := obs.RecorderMake[string](2)
+	x.Clock = clock
+
+	x.Sample("a")
+	clock.Advance(time.Second)
+	x.Sample("b")
+	clock.Advance(time.Second)
+	x.Sample("c") // evicts "a"
+
+	want := []obs.Event[string]{
+		{Time: start.Add(time.Second), Value: "b"},
+		{Time: start.Add(2 * time.Second), Value: "c"},
+	}
+	if got := x.Load(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := x.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "{\"Time\":\"1970-01-01T00:00:01Z\",\"Value\":\"b\"}\n{\"Time\":\"1970-01-01T00:00:02Z\",\"Value\":\"c\"}\n"; got != want {
+		t.Errorf("Dump: got %q, want %q", got, want)
+	}
+
+	// fed alongside a Sampler
+	sum := obs.SamplerMake(4, func(s *int, v string) { *s += len(v) })
+	obs.Start(sum)
+	obs.Tee[string]{sum, x}.Sample("dd")
+	if got := obs.StopAndCollect(sum); got != 2 {
+		t.Errorf("Sampler: got %d", got)
+	}
+	if e := x.Events(); len(e) != 2 || e[1].Value != "dd" {
+		t.Errorf("after Tee: got %v", e)
+	}
+}
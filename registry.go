@@ -0,0 +1,105 @@
+package obs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A Registry manages the lifecycle of a named set of Samplers, regardless of their type parameters.
+//
+// Its methods are concurrent safe.
+type Registry struct {
+	samplers map[string]AnySampler
+	mux      sync.Mutex
+}
+
+func RegistryMake() *Registry {
+	return &Registry{
+		samplers: make(map[string]AnySampler),
+	}
+}
+
+func (x *Registry) Delete(name string) {
+	x.mux.Lock()
+	delete(x.samplers, name)
+	x.mux.Unlock()
+}
+
+func (x *Registry) Get(name string) (AnySampler, bool) {
+	x.mux.Lock()
+	o, ok := x.samplers[name]
+	x.mux.Unlock()
+	return o, ok
+}
+
+func (x *Registry) Set(name string, val AnySampler) {
+	x.mux.Lock()
+	x.samplers[name] = val
+	x.mux.Unlock()
+}
+
+// StartAll starts all members.
+func (x *Registry) StartAll() {
+	x.mux.Lock()
+	for _, v := range x.samplers {
+		v.Start()
+	}
+	x.mux.Unlock()
+}
+
+// SnapshotResetAll captures the state of all members and resets it, in a single pass, returning the captured states by name.
+// Scraping this periodically yields the deltas since the previous scrape.
+//
+// The members are reset one after the other, so samples processed meanwhile may fall on either side of a reset,
+// skewing the deltas of different members relative to each other by a small amount.
+// Members that can't be reset (anything other than Samplers and Pools) are only loaded.
+func (x *Registry) SnapshotResetAll() map[string]any {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	o := make(map[string]any, len(x.samplers))
+	for k, v := range x.samplers {
+		if r, ok := v.(interface{ SnapshotReset() any }); ok {
+			o[k] = r.SnapshotReset()
+		} else {
+			o[k] = v.Load()
+		}
+	}
+	return o
+}
+
+// StopAll stops all members.
+func (x *Registry) StopAll() {
+	x.mux.Lock()
+	for _, v := range x.samplers {
+		v.Stop()
+	}
+	x.mux.Unlock()
+}
+
+// StopAllOrdered stops the given Samplers one at a time, in order, waiting for each to finish processing before
+// stopping the next. Useful when a Sampler's Final feeds into another's input.
+// Members that don't provide a Done method, like Samplers and Pools do, are stopped without waiting.
+//
+// Returns an error wrapping ErrTimeout if all of them haven't finished within the timeout, in which case
+// the remaining ones are left running.
+func StopAllOrdered(order []AnySampler, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for i, v := range order {
+		v.Stop()
+
+		d, ok := v.(interface{ Done() <-chan struct{} })
+		if !ok {
+			continue
+		}
+		select {
+		case <-d.Done():
+		case <-timer.C:
+			return fmt.Errorf("stopping sampler %d of %d: %w", i+1, len(order), ErrTimeout)
+		}
+	}
+	return nil
+}
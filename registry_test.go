@@ -0,0 +1,95 @@
+package obs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStopAllOrdered(t *testing.T) {
+	a := SamplerMake(1, func(s *int, v int) { *s += v })
+	b := SamplerMake(1, func(s *int, v int) { *s += v })
+	Start(a)
+	Start(b)
+	Sample(a, 1)
+	Sample(b, 1)
+
+	if err := StopAllOrdered([]AnySampler{a, b}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if Snapshot(a) != 1 || Snapshot(b) != 1 {
+		t.Errorf("processed %d and %d samples, want 1 each", Snapshot(a), Snapshot(b))
+	}
+}
+
+func TestStopAllOrderedTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	a := SamplerMake(1, func(s *int, v int) { <-release })
+	b := SamplerMake(1, func(s *int, v int) {})
+	Start(a)
+	Start(b)
+	defer b.Stop()
+	Sample(a, 0)
+
+	err := StopAllOrdered([]AnySampler{a, b}, 10*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if want := "stopping sampler 1 of 2: " + ErrTimeout.Error(); err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	sum := summing(4)
+	words := SamplerMake(4, func(s *string, v string) { *s += v })
+
+	r := RegistryMake()
+	r.Set("sum", sum)
+	r.Set("words", words)
+	r.StartAll()
+	sum.Sample(1)
+	sum.Sample(2)
+	words.Sample("a")
+	words.Sample("b")
+	r.StopAll()
+	<-sum.Done()
+	<-words.Done()
+
+	if x, ok := r.Get("sum"); !ok || x.Load() != 3 {
+		t.Errorf("sum: got %v", x.Load())
+	}
+	if x, ok := r.Get("words"); !ok || x.Load() != "ab" {
+		t.Errorf("words: got %v", x.Load())
+	}
+
+	r.Delete("sum")
+	if _, ok := r.Get("sum"); ok {
+		t.Error("deleted member still present")
+	}
+}
+
+func TestSnapshotResetAll(t *testing.T) {
+	a, b := summing(4), summing(4)
+	r := RegistryMake()
+	r.Set("a", a)
+	r.Set("b", b)
+	r.StartAll()
+	defer r.StopAll()
+
+	scrape := func(va, vb int) map[string]any {
+		Sample(a, va)
+		Sample(b, vb)
+		Flush(a)
+		Flush(b)
+		return r.SnapshotResetAll()
+	}
+	if got, want := scrape(1, 10), map[string]any{"a": 1, "b": 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first scrape: got %v, want %v", got, want)
+	}
+	if got, want := scrape(2, 20), map[string]any{"a": 2, "b": 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second scrape: got %v, want the deltas %v", got, want)
+	}
+}
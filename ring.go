@@ -0,0 +1,38 @@
+package obs
+
+// ring is a fixed size buffer that retains the most recently pushed values.
+type ring[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+func ringMake[T any](size int) ring[T] {
+	return ring[T]{
+		values: make([]T, size),
+	}
+}
+
+func (x *ring[T]) push(v T) {
+	if len(x.values) == 0 {
+		return
+	}
+
+	x.values[x.next] = v
+	x.next++
+	if x.next == len(x.values) {
+		x.next = 0
+		x.full = true
+	}
+}
+
+// slice returns a copy of the retained values, oldest first.
+func (x *ring[T]) slice() []T {
+	if !x.full {
+		return append([]T(nil), x.values[:x.next]...)
+	}
+
+	o := make([]T, 0, len(x.values))
+	o = append(o, x.values[x.next:]...)
+	return append(o, x.values[:x.next]...)
+}
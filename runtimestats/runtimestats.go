@@ -0,0 +1,187 @@
+// Package runtimestats exposes standard Go runtime statistics, read from runtime/metrics, as obs Values.
+package runtimestats
+
+import (
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/blitz-frost/obs"
+)
+
+// A Stats reads the runtime statistics exposed by its Values.
+// The statistics are read together, lazily, when a Value is loaded and the last reading is older than the maximum age,
+// so an export of all the Values costs a single reading.
+//
+// Its methods are concurrent safe.
+type Stats struct {
+	Clock obs.Clock // time source for the maximum age; the real clock if nil
+
+	maxAge  time.Duration
+	samples []metrics.Sample
+	index   map[string]int // position of each metric in samples
+
+	read time.Time
+	mux  sync.Mutex
+}
+
+// A stat describes a Value derived from a runtime metric.
+type stat struct {
+	label  string
+	unit   string
+	help   string
+	metric string
+	kind   string
+	q      float64 // quantile, for histogram metrics
+}
+
+var stats = []stat{
+	{"goroutines", "", "Live goroutines.", "/sched/goroutines:goroutines", "gauge", 0},
+	{"gomaxprocs", "", "Current GOMAXPROCS setting.", "/sched/gomaxprocs:threads", "gauge", 0},
+	{"heap.objects", "bytes", "Memory occupied by live and not yet swept heap objects.", "/memory/classes/heap/objects:bytes", "gauge", 0},
+	{"heap.goal", "bytes", "Heap size target for the end of the GC cycle.", "/gc/heap/goal:bytes", "gauge", 0},
+	{"memory.total", "bytes", "Memory mapped by the Go runtime.", "/memory/classes/total:bytes", "gauge", 0},
+	{"gc.cycles", "", "Completed GC cycles.", "/gc/cycles/total:gc-cycles", "counter", 0},
+	{"gc.allocs", "bytes", "Cumulative heap allocations.", "/gc/heap/allocs:bytes", "counter", 0},
+	{"gc.pause.p50", "seconds", "Median stop-the-world GC pause.", "/sched/pauses/total/gc:seconds", "gauge", 0.5},
+	{"gc.pause.p99", "seconds", "99th percentile stop-the-world GC pause.", "/sched/pauses/total/gc:seconds", "gauge", 0.99},
+	{"gc.pause.max", "seconds", "Longest stop-the-world GC pause.", "/sched/pauses/total/gc:seconds", "gauge", 1},
+	{"sched.latency.p50", "seconds", "Median time goroutines spent runnable before running.", "/sched/latencies:seconds", "gauge", 0.5},
+	{"sched.latency.p99", "seconds", "99th percentile time goroutines spent runnable before running.", "/sched/latencies:seconds", "gauge", 0.99},
+	{"sched.latency.max", "seconds", "Longest time a goroutine spent runnable before running.", "/sched/latencies:seconds", "gauge", 1},
+}
+
+// Register sets Values for goroutine count, heap size, GC cycles, GC pauses and scheduling latency in m,
+// and returns the Stats reading them. Readings are reused while younger than maxAge; 0 reads on every Load.
+// Pause and latency quantiles cover the whole life of the process.
+// Statistics the running Go version doesn't provide are left out.
+//
+// Labels are unprefixed (e.g. "goroutines"); registering in a child Map, such as m.Child("go"), namespaces them.
+// Values are keyed by their labels, as strings.
+func Register(m *obs.Map, maxAge time.Duration) *Stats {
+	x := &Stats{
+		maxAge: maxAge,
+		index:  make(map[string]int),
+	}
+
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+
+	for _, s := range stats {
+		if !supported[s.metric] {
+			continue
+		}
+		if _, ok := x.index[s.metric]; !ok {
+			x.index[s.metric] = len(x.samples)
+			x.samples = append(x.samples, metrics.Sample{Name: s.metric})
+		}
+	}
+
+	for _, s := range stats {
+		i, ok := x.index[s.metric]
+		if !ok {
+			continue
+		}
+		m.Set(s.label, obs.Value{
+			Label:  s.label,
+			Loader: loader{x, i, s.kind, s.q},
+			Help:   s.help,
+			Unit:   s.unit,
+		})
+	}
+	return x
+}
+
+// Refresh reads the statistics now, regardless of the age of the last reading.
+func (x *Stats) Refresh() {
+	x.mux.Lock()
+	x.refresh(x.now())
+	x.mux.Unlock()
+}
+
+func (x *Stats) now() time.Time {
+	if x.Clock == nil {
+		return obs.RealClock.Now()
+	}
+	return x.Clock.Now()
+}
+
+func (x *Stats) refresh(now time.Time) {
+	metrics.Read(x.samples)
+	x.read = now
+}
+
+// value returns the i-th metric, reading the statistics first if stale.
+func (x *Stats) value(i int, q float64) float64 {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	now := x.now()
+	if x.read.IsZero() || now.Sub(x.read) >= x.maxAge {
+		x.refresh(now)
+	}
+
+	v := x.samples[i].Value
+	switch v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64())
+	case metrics.KindFloat64:
+		return v.Float64()
+	case metrics.KindFloat64Histogram:
+		return quantile(v.Float64Histogram(), q)
+	}
+	return math.NaN()
+}
+
+// quantile estimates the q quantile of a runtime histogram, as the upper boundary of the bucket it falls into.
+// Unbounded buckets are reported by their finite boundary. Returns 0 for empty histograms.
+func quantile(h *metrics.Float64Histogram, q float64) float64 {
+	var total uint64
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	rank = max(rank, 1)
+
+	var sum uint64
+	i := 0
+	for ; i < len(h.Counts); i++ {
+		sum += h.Counts[i]
+		if sum >= rank {
+			break
+		}
+	}
+
+	// bucket i spans Buckets[i] to Buckets[i+1]
+	if upper := h.Buckets[i+1]; !math.IsInf(upper, 1) {
+		return upper
+	}
+	return h.Buckets[i]
+}
+
+type loader struct {
+	stats *Stats
+	i     int
+	kind  string
+	q     float64
+}
+
+func (x loader) AsFloat64() (float64, bool) {
+	f := x.stats.value(x.i, x.q)
+	return f, !math.IsNaN(f)
+}
+
+func (x loader) Kind() string {
+	return x.kind
+}
+
+func (x loader) Load() any {
+	return x.stats.value(x.i, x.q)
+}
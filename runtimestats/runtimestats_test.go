@@ -0,0 +1,82 @@
+package runtimestats
+
+import (
+	"math"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestRegister(t *testing.T) {
+	m := obs.MapMake()
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := Register(m, time.Second)
+	x.Clock = clock
+
+	goroutines := func() float64 {
+		t.Helper()
+		v, ok := m.Get("goroutines")
+		if !ok {
+			t.Fatal("goroutines not registered")
+		}
+		f, ok := obs.LoadFloat(v)
+		if !ok {
+			t.Fatal("goroutines not numeric")
+		}
+		return f
+	}
+
+	before := goroutines()
+	if before < 1 {
+		t.Errorf("got %v goroutines", before)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	for i := 0; i < 10; i++ {
+		go func() { <-done }()
+	}
+
+	// the reading is reused until it is maxAge old
+	if got := goroutines(); got != before {
+		t.Errorf("within maxAge: got %v, want the reused %v", got, before)
+	}
+	clock.Advance(time.Second)
+	if got := goroutines(); got < before+10 {
+		t.Errorf("after maxAge: got %v, want at least %v", got, before+10)
+	}
+
+	if v, _ := m.Get("gc.cycles"); v.Loader.(obs.Kinded).Kind() != "counter" {
+		t.Error("gc.cycles is not a counter")
+	}
+	for _, e := range m.Snapshot() {
+		if f := e.Value.(float64); math.IsNaN(f) || f < 0 {
+			t.Errorf("%s: got %v", e.Label, f)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{1, 2, 1},
+		Buckets: []float64{0, 1, 2, math.Inf(1)},
+	}
+	for _, c := range []struct {
+		q, want float64
+	}{
+		{0, 1},
+		{0.5, 2},
+		{0.75, 2},
+		{1, 2}, // unbounded bucket, reported by its finite boundary
+	} {
+		if got := quantile(h, c.q); got != c.want {
+			t.Errorf("quantile %v: got %v, want %v", c.q, got, c.want)
+		}
+	}
+	if got := quantile(&metrics.Float64Histogram{Counts: []uint64{0}, Buckets: []float64{0, 1}}, 0.5); got != 0 {
+		t.Errorf("empty: got %v", got)
+	}
+}
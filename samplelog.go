@@ -0,0 +1,68 @@
+package obs
+
+import "sync"
+
+// A SampleLog records the samples processed by a Sampler, in order, for later inspection or Replay.
+// Unlike the history kept by KeepHistory, it retains the first samples rather than the most recent ones,
+// so that a sequence can be reproduced from the start.
+//
+// Its methods are concurrent safe.
+type SampleLog[T any] struct {
+	limit     int
+	samples   []T
+	truncated bool
+	paused    bool
+	mux       sync.Mutex
+}
+
+// SampleLogMake returns a SampleLog retaining at most limit samples.
+func SampleLogMake[T any](limit int) *SampleLog[T] {
+	return &SampleLog[T]{
+		limit: limit,
+	}
+}
+
+// Pause stops or resumes recording. Samples processed while paused are not recorded.
+func (x *SampleLog[T]) Pause(paused bool) {
+	x.mux.Lock()
+	x.paused = paused
+	x.mux.Unlock()
+}
+
+// Samples returns a copy of the recorded samples, in processing order.
+func (x *SampleLog[T]) Samples() []T {
+	x.mux.Lock()
+	o := append([]T(nil), x.samples...)
+	x.mux.Unlock()
+	return o
+}
+
+// Truncated reports whether samples have been left out because the limit was reached.
+func (x *SampleLog[T]) Truncated() bool {
+	x.mux.Lock()
+	o := x.truncated
+	x.mux.Unlock()
+	return o
+}
+
+func (x *SampleLog[T]) record(v T) {
+	x.mux.Lock()
+	switch {
+	case x.paused:
+	case len(x.samples) < x.limit:
+		x.samples = append(x.samples, v)
+	default:
+		x.truncated = true
+	}
+	x.mux.Unlock()
+}
+
+// Replay runs a sequence of samples through a sample function, synchronously, and returns the resulting state.
+// Paired with a SampleLog, it reproduces the aggregation of a live Sampler.
+func Replay[S any, T any](fn func(*S, T), samples []T) S {
+	var o S
+	for _, v := range samples {
+		fn(&o, v)
+	}
+	return o
+}
@@ -0,0 +1,42 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSampleLogReplay(t *testing.T) {
+	fn := func(s *[]int, v int) {
+		// order sensitive, so that a replay in another order would differ
+		*s = append(*s, len(*s)*v)
+	}
+	log := SampleLogMake[int](10)
+	x := SamplerMake(8, fn)
+	x.Log = log
+	Start(x)
+	for _, v := range []int{3, 1, 4, 1, 5} {
+		Sample(x, v)
+	}
+	live := StopAndCollect(x)
+
+	if got := Replay(fn, log.Samples()); !reflect.DeepEqual(got, live) {
+		t.Errorf("replay: got %v, want the live state %v", got, live)
+	}
+	if log.Truncated() {
+		t.Error("truncated below the limit")
+	}
+}
+
+func TestSampleLogLimit(t *testing.T) {
+	log := SampleLogMake[int](2)
+	log.record(1)
+	log.Pause(true)
+	log.record(2)
+	log.Pause(false)
+	log.record(3)
+	log.record(4)
+
+	if got := log.Samples(); !reflect.DeepEqual(got, []int{1, 3}) || !log.Truncated() {
+		t.Errorf("got %v, truncated %v", got, log.Truncated())
+	}
+}
@@ -0,0 +1,113 @@
+package obs
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A SamplingPolicy decides which samples a Sampler accepts at all. It is consulted by the producer, before the sample
+// is cloned, transformed or queued, so that rejected samples cost next to nothing.
+// Implementations must be concurrent safe, as producers may consult them at the same time.
+type SamplingPolicy interface {
+	Accept() bool
+}
+
+// SamplingFunc adapts a function to the SamplingPolicy interface.
+type SamplingFunc func() bool
+
+func (x SamplingFunc) Accept() bool {
+	return x()
+}
+
+// EveryNth returns a SamplingPolicy accepting the first of every n samples. Values below 2 accept everything.
+func EveryNth(n int) SamplingPolicy {
+	return &everyNth{n: uint64(max(n, 1))}
+}
+
+type everyNth struct {
+	n     uint64
+	count atomic.Uint64
+}
+
+func (x *everyNth) Accept() bool {
+	return (x.count.Add(1)-1)%x.n == 0
+}
+
+// Probability returns a SamplingPolicy accepting each sample independently, with probability p.
+func Probability(p float64) SamplingPolicy {
+	return probability(p)
+}
+
+type probability float64
+
+func (x probability) Accept() bool {
+	return rand.Float64() < float64(x)
+}
+
+// A RateLimit is a SamplingPolicy accepting at most a given number of samples per second, on average.
+// Its allowance accumulates while idle, up to one second's worth, so short bursts are accepted whole.
+type RateLimit struct {
+	Clock Clock // time source for refilling the allowance; the real clock if nil
+
+	rate   float64
+	tokens float64
+	last   time.Time
+	mux    sync.Mutex
+}
+
+// RateLimitMake returns a RateLimit of perSecond samples per second, starting with a full allowance.
+func RateLimitMake(perSecond float64) *RateLimit {
+	return &RateLimit{
+		rate:   perSecond,
+		tokens: max(perSecond, 1),
+	}
+}
+
+func (x *RateLimit) Accept() bool {
+	now := clockOr(x.Clock).Now()
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if !x.last.IsZero() {
+		x.tokens = min(x.tokens+now.Sub(x.last).Seconds()*x.rate, max(x.rate, 1))
+	}
+	x.last = now
+
+	if x.tokens < 1 {
+		return false
+	}
+	x.tokens--
+	return true
+}
+
+// Unsampled returns the number of samples rejected by the Sampler's SamplingPolicy.
+func Unsampled[S any, T any](x *Sampler[S, T]) uint64 {
+	return x.unsampled.Load()
+}
+
+// sample applies the Sampler's SamplingPolicy to an item, filtering batches sample by sample.
+// Returns false if nothing is left of the item.
+func sample[S any, T any](x *Sampler[S, T], it *item[T]) bool {
+	if it.batch == nil {
+		if x.Sampling.Accept() {
+			return true
+		}
+		x.unsampled.Add(1)
+		return false
+	}
+
+	// batches are private copies, so they can be filtered in place
+	kept := it.batch[:0]
+	for _, v := range it.batch {
+		if x.Sampling.Accept() {
+			kept = append(kept, v)
+		} else {
+			x.unsampled.Add(1)
+		}
+	}
+	it.batch = kept
+	return len(kept) > 0
+}
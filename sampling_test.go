@@ -0,0 +1,79 @@
+package obs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestEveryNth(t *testing.T) {
+	x := obs.SamplerMake(16, func(s *[]int, v int) { *s = append(*s, v) })
+	x.Sampling = obs.EveryNth(3)
+	obs.Start(x)
+	for i := 0; i < 7; i++ {
+		if err := obs.SampleErr(x, i); err != nil {
+			t.Errorf("sample %d: rejection reported as %v", i, err)
+		}
+	}
+	obs.SampleBatch(x, []int{7, 8, 9})
+	got := obs.StopAndCollect(x)
+	if want := []int{0, 3, 6, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := obs.Unsampled(x); n != 6 {
+		t.Errorf("unsampled: got %d, want 6", n)
+	}
+}
+
+func TestProbability(t *testing.T) {
+	for _, c := range []struct {
+		p        float64
+		min, max int
+	}{
+		{0, 0, 0},
+		{1, 10000, 10000},
+		{0.25, 2200, 2800},
+	} {
+		x := obs.Probability(c.p)
+		n := 0
+		for i := 0; i < 10000; i++ {
+			if x.Accept() {
+				n++
+			}
+		}
+		if n < c.min || n > c.max {
+			t.Errorf("p %v: accepted %d of 10000", c.p, n)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.RateLimitMake(10)
+	x.Clock = clock
+
+	accepted := func() int {
+		n := 0
+		for i := 0; i < 100; i++ {
+			if x.Accept() {
+				n++
+			}
+		}
+		return n
+	}
+	if n := accepted(); n != 10 {
+		t.Errorf("initial burst: accepted %d, want 10", n)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if n := accepted(); n != 5 {
+		t.Errorf("after half a second: accepted %d, want 5", n)
+	}
+	// the allowance accumulates up to one second's worth
+	clock.Advance(time.Minute)
+	if n := accepted(); n != 10 {
+		t.Errorf("after idling: accepted %d, want 10", n)
+	}
+}
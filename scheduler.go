@@ -0,0 +1,92 @@
+package obs
+
+import (
+	"sync"
+	"time"
+)
+
+// A Scheduler periodically exports the loaded contents of a Map, keyed by label.
+// Combined with a Sink, it reports values without a scrape endpoint, for example to the log every 30 seconds:
+//
+//	s := obs.SchedulerMake(m, 30*time.Second, obs.SlogSink(slog.Default(), slog.LevelInfo, "metrics").Write)
+//	s.ExportOnStop = true
+//	s.Start()
+//	defer s.Stop()
+type Scheduler struct {
+	Clock        Clock       // time source for the export ticker; the real clock if nil
+	Error        func(error) // called with export errors, if non-nil
+	ExportOnStop bool        // export once more on Stop, so that the final values are reported
+
+	m        *Map
+	interval time.Duration
+	export   func(map[string]any) error
+
+	mu   sync.Mutex // guards done
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func SchedulerMake(m *Map, interval time.Duration, export func(map[string]any) error) *Scheduler {
+	return &Scheduler{
+		m:        m,
+		interval: interval,
+		export:   export,
+	}
+}
+
+// Start launches the export goroutine.
+// NoOp if the Scheduler is already running.
+// Safe to call concurrently with Stop.
+func (x *Scheduler) Start() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.done != nil {
+		return
+	}
+
+	x.done = make(chan struct{})
+	x.wg.Add(1)
+	go x.loop(x.done, clockOr(x.Clock).NewTicker(x.interval))
+}
+
+// Stop terminates the export goroutine and waits for it to exit.
+// Must be called when the Scheduler is no longer needed.
+func (x *Scheduler) Stop() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.done == nil {
+		return
+	}
+
+	close(x.done)
+	x.wg.Wait()
+	x.done = nil
+}
+
+func (x *Scheduler) loop(done chan struct{}, ticker Ticker) {
+	defer x.wg.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			if x.ExportOnStop {
+				x.exportNow()
+			}
+			return
+		case <-ticker.C():
+		}
+
+		x.exportNow()
+	}
+}
+
+func (x *Scheduler) exportNow() {
+	values := x.m.labeled()
+
+	if err := x.export(values); err != nil && x.Error != nil {
+		x.Error(err)
+	}
+}
@@ -0,0 +1,102 @@
+package obs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	m := MapOf(Value{Label: "a", Loader: constant(1)})
+	errExport := errors.New("export failed")
+
+	var mux sync.Mutex
+	var calls, errs int
+	s := SchedulerMake(m, time.Millisecond, func(v map[string]any) error {
+		mux.Lock()
+		defer mux.Unlock()
+		if v["a"] != 1 {
+			t.Errorf("exported %v", v)
+		}
+		calls++
+		return errExport
+	})
+	s.Error = func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		if err != errExport {
+			t.Errorf("got error %v", err)
+		}
+		errs++
+	}
+
+	s.Start()
+	s.Start() // NoOp
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		mux.Lock()
+		n := calls
+		mux.Unlock()
+		if n >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("exported %d times in 5s", n)
+		}
+	}
+	s.Stop()
+	s.Stop() // NoOp
+
+	mux.Lock()
+	n := calls
+	if errs != calls {
+		t.Errorf("reported %d errors for %d exports", errs, calls)
+	}
+	mux.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	mux.Lock()
+	defer mux.Unlock()
+	if calls != n {
+		t.Errorf("exported %d times after Stop", calls-n)
+	}
+}
+
+func TestSchedulerExportOnStop(t *testing.T) {
+	var calls int
+	s := SchedulerMake(MapMake(), time.Hour, func(map[string]any) error {
+		calls++
+		return nil
+	})
+	s.ExportOnStop = true
+	s.Start()
+	s.Stop()
+	if calls != 1 {
+		t.Errorf("exported %d times, want once on Stop", calls)
+	}
+}
+
+func TestSchedulerConcurrent(t *testing.T) {
+	s := SchedulerMake(MapMake(), time.Millisecond, func(map[string]any) error { return nil })
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Start()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+	s.Stop()
+	if s.done != nil {
+		t.Error("still running after Stop")
+	}
+}
@@ -0,0 +1,80 @@
+package obs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A shard is a producer side buffer of a sharded Sampler.
+type shard[T any] struct {
+	buf []T
+	mux sync.Mutex
+	_   [64]byte // keeps neighboring shards off the same cache line
+}
+
+// SetShards spreads producers over n buffers of up to batchSize samples, each queued as a single batch once full,
+// which removes the queue as a point of contention when many goroutines sample at once.
+// Partially filled buffers are collected by the processing goroutine every flushInterval (using Clock),
+// and once more after the queue is drained on Stop.
+//
+// Samples from different buffers are processed out of order, and only appear in Stats once they have been queued.
+// Acknowledged samples bypass the buffers. Full buffers are queued as by SampleBatch, so overflow handling and Quota
+// apply to whole batches. A full buffer refused because Stop raced in is counted as dropped.
+//
+// Must be called before Start. Not supported in coalescing mode.
+func SetShards[S any, T any](x *Sampler[S, T], n, batchSize int, flushInterval time.Duration) error {
+	if n < 1 || batchSize < 1 || flushInterval <= 0 {
+		return fmt.Errorf("invalid sharding: %v shards of %v, flushed every %v", n, batchSize, flushInterval)
+	}
+	if x.equal != nil {
+		return fmt.Errorf("sharding not supported in coalescing mode")
+	}
+
+	x.shards = make([]shard[T], n)
+	for i := range x.shards {
+		x.shards[i].buf = make([]T, 0, batchSize)
+	}
+	x.shardBatch = batchSize
+	x.shardFlush = flushInterval
+	return nil
+}
+
+// pushShard buffers a sample in one of the Sampler's shards, queuing the shard's contents if it fills up.
+func pushShard[S any, T any](x *Sampler[S, T], it item[T]) error {
+	s := &x.shards[x.shardNext.Add(1)%uint64(len(x.shards))]
+
+	s.mux.Lock()
+	if x.closed.Load() {
+		// the processing goroutine may have collected the shards for the last time
+		s.mux.Unlock()
+		return ErrInactive
+	}
+	s.buf = append(s.buf, it.v)
+	if len(s.buf) < x.shardBatch {
+		s.mux.Unlock()
+		return nil
+	}
+	batch := s.buf
+	s.buf = make([]T, 0, x.shardBatch)
+	s.mux.Unlock()
+
+	return enqueue(x, item[T]{batch: batch, shard: true})
+}
+
+// flushShards processes the contents of all shards, on behalf of the processing goroutine.
+func flushShards[S any, T any](x *Sampler[S, T], ch chan item[T], first *bool) {
+	for i := range x.shards {
+		s := &x.shards[i]
+		s.mux.Lock()
+		batch := s.buf
+		if len(batch) > 0 {
+			s.buf = make([]T, 0, x.shardBatch)
+		}
+		s.mux.Unlock()
+
+		if len(batch) > 0 {
+			processBatch(x, ch, batch, first)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package obs
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	x := summing(8)
+	x.Strategy = BlockOnOverflow
+	if err := SetShards(x, 4, 16, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	Start(x)
+
+	// partially filled buffers are collected by Flush
+	Sample(x, 1)
+	Sample(x, 2)
+	Flush(x)
+	if got := Snapshot(x); got != 3 {
+		t.Errorf("after Flush: got %d, want 3", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				Sample(x, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	// and by Stop
+	if got := StopAndCollect(x); got != 8003 {
+		t.Errorf("got %d, want 8003", got)
+	}
+}
+
+func TestShardsInvalid(t *testing.T) {
+	if err := SetShards(summing(1), 0, 16, time.Second); err == nil {
+		t.Error("no shards: got nil error")
+	}
+	if err := SetShards(summing(1), 4, 16, 0); err == nil {
+		t.Error("no flush interval: got nil error")
+	}
+	x := SamplerMakeCoalesce(1, func(a, b int) bool { return a == b }, func(s *int, v int, n int) { *s += v * n })
+	if err := SetShards(x, 4, 16, time.Second); err == nil {
+		t.Error("coalescing: got nil error")
+	}
+}
+
+func TestShardsStopRace(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		x := summing(64)
+		x.Strategy = BlockOnOverflow
+		if err := SetShards(x, 2, 2, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		Start(x)
+
+		// shards keep filling up as Stop closes the Sampler
+		var accepted, rejected atomic.Int64
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for SampleErr(x, 1) == nil {
+					accepted.Add(1)
+				}
+				rejected.Add(1)
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		Stop(x)
+		wg.Wait()
+
+		// rejected samples may be counted as dropped too, like on any overflow
+		got := int64(StopAndCollect(x))
+		if n := got + int64(Dropped(x)); n < accepted.Load() || n > accepted.Load()+rejected.Load() {
+			t.Fatalf("%d processed or dropped, for %d accepted and %d rejected samples", n, accepted.Load(), rejected.Load())
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package obs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// A Sink consumes snapshots of loaded values, keyed by label, such as those produced by a Scheduler.
+// Tagged members are keyed by their label followed by their tags, in OpenMetrics label syntax (e.g. `requests{code="200"}`).
+type Sink interface {
+	Write(map[string]any) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(map[string]any) error
+
+func (x SinkFunc) Write(v map[string]any) error {
+	return x(v)
+}
+
+// JSONSink returns a Sink that writes each snapshot to w as a JSON object, followed by a newline.
+func JSONSink(w io.Writer) Sink {
+	enc := json.NewEncoder(w)
+	return SinkFunc(func(v map[string]any) error {
+		return enc.Encode(v)
+	})
+}
+
+// SlogSink returns a Sink that logs each snapshot as a single record with the given level and message,
+// with an attribute per value, sorted by label.
+func SlogSink(logger *slog.Logger, level slog.Level, msg string) Sink {
+	return SinkFunc(func(v map[string]any) error {
+		labels := make([]string, 0, len(v))
+		for label := range v {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		attrs := make([]slog.Attr, len(labels))
+		for i, label := range labels {
+			attrs[i] = slog.Any(label, v[label])
+		}
+		logger.LogAttrs(context.Background(), level, msg, attrs...)
+		return nil
+	})
+}
+
+// OpenMetricsSink returns a Sink that writes each snapshot to w in the OpenMetrics text exposition format.
+// Snapshots carry no metadata, so all numeric values are exposed as gauges.
+func OpenMetricsSink(w io.Writer) Sink {
+	return SinkFunc(func(v map[string]any) error {
+		return writeOpenMetrics(w, snapshotValues(v))
+	})
+}
+
+// A BatchSink collects snapshots and forwards them to another Sink in batches of a fixed size.
+//
+// Its methods are concurrent safe.
+type BatchSink struct {
+	inner   Sink
+	size    int
+	pending []map[string]any
+	mux     sync.Mutex
+}
+
+func BatchSinkMake(inner Sink, size int) *BatchSink {
+	return &BatchSink{
+		inner: inner,
+		size:  size,
+	}
+}
+
+// Flush forwards all pending snapshots, in order.
+// On error, the failed snapshot and those after it remain pending, to be retried by the next flush.
+func (x *BatchSink) Flush() error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	return x.flush()
+}
+
+// Pending returns the number of snapshots waiting to be forwarded.
+func (x *BatchSink) Pending() int {
+	x.mux.Lock()
+	o := len(x.pending)
+	x.mux.Unlock()
+	return o
+}
+
+// Write adds a snapshot to the current batch, flushing it if full.
+func (x *BatchSink) Write(v map[string]any) error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	x.pending = append(x.pending, v)
+	if len(x.pending) < x.size {
+		return nil
+	}
+	return x.flush()
+}
+
+func (x *BatchSink) flush() error {
+	for i, v := range x.pending {
+		if err := x.inner.Write(v); err != nil {
+			x.pending = x.pending[:copy(x.pending, x.pending[i:])]
+			return err
+		}
+	}
+	x.pending = x.pending[:0]
+	return nil
+}
+
+// snapshotValues converts a snapshot back into Values, recovering the tags of tagged keys, sorted by label, then by tags.
+func snapshotValues(v map[string]any) []Value {
+	o := make([]Value, 0, len(v))
+	for k, loaded := range v {
+		label, tags := parseTaggedLabel(k)
+		o = append(o, Value{Label: label, Tags: tags, Loader: frozen{v: loaded}})
+	}
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Label != o[j].Label {
+			return o[i].Label < o[j].Label
+		}
+		return openMetricsLabels(o[i].Tags) < openMetricsLabels(o[j].Tags)
+	})
+	return o
+}
@@ -0,0 +1,83 @@
+package obs
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestBatchSink(t *testing.T) {
+	var got []any
+	var fail error
+	inner := SinkFunc(func(v map[string]any) error {
+		if fail != nil {
+			return fail
+		}
+		got = append(got, v["n"])
+		return nil
+	})
+	x := BatchSinkMake(inner, 3)
+
+	x.Write(map[string]any{"n": 1})
+	x.Write(map[string]any{"n": 2})
+	if len(got) != 0 || x.Pending() != 2 {
+		t.Fatalf("forwarded %v before the batch filled", got)
+	}
+	x.Write(map[string]any{"n": 3})
+	if len(got) != 3 || x.Pending() != 0 {
+		t.Fatalf("full batch: forwarded %v, %d pending", got, x.Pending())
+	}
+
+	x.Write(map[string]any{"n": 4})
+	if err := x.Flush(); err != nil || len(got) != 4 {
+		t.Fatalf("Flush: %v, forwarded %v", err, got)
+	}
+
+	fail = errors.New("unavailable")
+	x.Write(map[string]any{"n": 5})
+	x.Write(map[string]any{"n": 6})
+	if err := x.Write(map[string]any{"n": 7}); err != fail {
+		t.Errorf("propagated %v, want the inner error", err)
+	}
+	if x.Pending() != 3 {
+		t.Errorf("%d pending after a failed flush, want 3 kept for retry", x.Pending())
+	}
+
+	fail = nil
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 || got[4] != 5 || got[6] != 7 {
+		t.Errorf("retry: forwarded %v, want the failed batch in order", got)
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var b bytes.Buffer
+	if err := JSONSink(&b).Write(map[string]any{"b": 2, "a": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != `{"a":"x","b":2}`+"\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	x := SlogSink(logger, slog.LevelWarn, "metrics")
+	if err := x.Write(map[string]any{"b": 2, "a": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "level=WARN msg=metrics a=x b=2\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
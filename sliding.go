@@ -0,0 +1,111 @@
+package obs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A SlidingWindow aggregates numbers over a sliding time window, for use as a Sampler state.
+// The window is split into equal buckets, expiring one at a time as time advances.
+// Add is a sample function, for use with SamplerMakeState.
+//
+// It is not concurrent safe on its own; as a Sampler state, it is protected by the Sampler.
+// It encodes to JSON as its current Stats, and therefore can't be restored by RestoreState.
+type SlidingWindow[T Number] struct {
+	Clock Clock // time source for bucketing; the real clock if nil
+
+	ring bucketRing[windowBucket[T]]
+}
+
+type windowBucket[T Number] struct {
+	count uint64
+	sum   float64
+	min   T
+	max   T
+}
+
+// WindowStats summarize the samples of a SlidingWindow.
+// Min, Max and Mean are meaningless while Count is 0, and Rate is 0 if the window isn't positive.
+type WindowStats[T Number] struct {
+	Count uint64
+	Sum   float64
+	Min   T
+	Max   T
+	Mean  float64
+	Rate  float64 // samples per second
+}
+
+// SlidingWindowMake returns a SlidingWindow over the given duration, made up of n buckets.
+// More buckets make the window slide more smoothly, at the cost of memory.
+// A SlidingWindow over a window that isn't positive, like the zero value, discards all samples.
+func SlidingWindowMake[T Number](window time.Duration, n int) *SlidingWindow[T] {
+	return &SlidingWindow[T]{
+		ring: bucketRingMake[windowBucket[T]](window, n),
+	}
+}
+
+// SlidingWindowSampler returns a Sampler aggregating its samples into a SlidingWindow.
+//
+// Like any Sampler, it is a Loader of its state, which encodes as the window's current Stats.
+func SlidingWindowSampler[T Number](queueSize int, window time.Duration, n int) *Sampler[SlidingWindow[T], T] {
+	return SamplerMakeState(queueSize, SlidingWindowMake[T](window, n), (*SlidingWindow[T]).Add)
+}
+
+func (x *SlidingWindow[T]) Add(v T) {
+	if !x.ring.valid() {
+		return
+	}
+	b := x.ring.at(x.ring.epoch(x.Clock))
+
+	if b.count == 0 || v < b.min {
+		b.min = v
+	}
+	if b.count == 0 || v > b.max {
+		b.max = v
+	}
+	b.count++
+	b.sum += float64(v)
+}
+
+func (x *SlidingWindow[T]) Clone() SlidingWindow[T] {
+	o := *x
+	o.ring = x.ring.clone()
+	return o
+}
+
+func (x SlidingWindow[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.Stats())
+}
+
+// Reset discards all samples, keeping the configuration.
+func (x *SlidingWindow[T]) Reset() {
+	x.ring.reset()
+}
+
+// Stats returns the aggregate of the samples within the window.
+func (x *SlidingWindow[T]) Stats() WindowStats[T] {
+	var o WindowStats[T]
+	if !x.ring.valid() {
+		return o
+	}
+
+	x.ring.each(x.ring.epoch(x.Clock), func(b *windowBucket[T]) {
+		if b.count == 0 {
+			return
+		}
+		if o.Count == 0 || b.min < o.Min {
+			o.Min = b.min
+		}
+		if o.Count == 0 || b.max > o.Max {
+			o.Max = b.max
+		}
+		o.Count += b.count
+		o.Sum += b.sum
+	})
+
+	if o.Count > 0 {
+		o.Mean = o.Sum / float64(o.Count)
+	}
+	o.Rate = float64(o.Count) / x.ring.window.Seconds()
+	return o
+}
@@ -0,0 +1,143 @@
+// Package sloghook derives obs samples from structured logging, by way of a slog.Handler.
+package sloghook
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/blitz-frost/obs"
+)
+
+// A Record is the sample taken of a log record.
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr // resolved and flattened, including those of the handler; group names are joined with "."
+}
+
+// Attr returns the value of the attribute with the given (flattened) key.
+// Returns false if the Record has no such attribute.
+func (x Record) Attr(key string) (slog.Value, bool) {
+	for _, a := range x.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
+// A Handler wraps another slog.Handler, additionally sampling the records it handles into a Consumer,
+// typically a Sampler, so that metrics such as error counts are driven directly by the logs.
+// Records are sampled if they are at least of the Handler's sampling level, whether or not the wrapped
+// Handler is enabled for them.
+type Handler struct {
+	inner slog.Handler
+	c     obs.Consumer[Record]
+	level slog.Leveler
+
+	attrs  []slog.Attr // flattened
+	prefix string      // of the open groups
+}
+
+// HandlerMake returns a Handler passing records on to inner, and samples of those at or above level to c.
+// A nil level samples every record.
+func HandlerMake(inner slog.Handler, c obs.Consumer[Record], level slog.Leveler) *Handler {
+	return &Handler{
+		inner: inner,
+		c:     c,
+		level: level,
+	}
+}
+
+func (x *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return x.sampled(level) || x.inner.Enabled(ctx, level)
+}
+
+func (x *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if x.sampled(r.Level) {
+		attrs := append(make([]slog.Attr, 0, len(x.attrs)+r.NumAttrs()), x.attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = flatten(attrs, x.prefix, a)
+			return true
+		})
+		x.c.Sample(Record{
+			Time:    r.Time,
+			Level:   r.Level,
+			Message: r.Message,
+			Attrs:   attrs,
+		})
+	}
+
+	if !x.inner.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return x.inner.Handle(ctx, r)
+}
+
+func (x *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	o := *x
+	o.inner = x.inner.WithAttrs(attrs)
+	o.attrs = append([]slog.Attr(nil), x.attrs...)
+	for _, a := range attrs {
+		o.attrs = flatten(o.attrs, x.prefix, a)
+	}
+	return &o
+}
+
+func (x *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return x
+	}
+	o := *x
+	o.inner = x.inner.WithGroup(name)
+	o.prefix = x.prefix + name + "."
+	return &o
+}
+
+func (x *Handler) sampled(level slog.Level) bool {
+	return x.level == nil || level >= x.level.Level()
+}
+
+// flatten appends a resolved attribute to dst, with the members of groups as separate attributes.
+// Empty attributes are left out, as slog handlers are expected to do.
+func flatten(dst []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return dst
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		a.Key = prefix + a.Key
+		return append(dst, a)
+	}
+
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, member := range a.Value.Group() {
+		dst = flatten(dst, prefix, member)
+	}
+	return dst
+}
+
+// LevelCounts is a state counting records by level.
+type LevelCounts map[slog.Level]uint64
+
+func (x LevelCounts) Clone() LevelCounts {
+	o := make(LevelCounts, len(x))
+	for k, v := range x {
+		o[k] = v
+	}
+	return o
+}
+
+// LevelCounterSampler returns a Sampler counting the records it is given by level, for error rates and the like.
+func LevelCounterSampler(queueSize int) *obs.Sampler[LevelCounts, Record] {
+	return obs.SamplerMake(queueSize, func(s *LevelCounts, r Record) {
+		if *s == nil {
+			*s = make(LevelCounts)
+		}
+		(*s)[r.Level]++
+	})
+}
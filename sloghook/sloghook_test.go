@@ -0,0 +1,71 @@
+package sloghook
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blitz-frost/obs"
+)
+
+// records is a Consumer retaining its samples.
+type records []Record
+
+func (x *records) Sample(r Record) {
+	*x = append(*x, r)
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	inner := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	var got records
+	logger := slog.New(HandlerMake(inner, &got, nil))
+
+	logger.Debug("sampled, not logged")
+	logger.With("service", "api").WithGroup("req").Warn("slow", "ms", 250, slog.Group("user", "id", 7))
+
+	if n := len(got); n != 2 {
+		t.Fatalf("got %d samples, want 2", n)
+	}
+	if got[0].Level != slog.LevelDebug || got[0].Message != "sampled, not logged" {
+		t.Errorf("first: got %+v", got[0])
+	}
+	var keys []string
+	for _, a := range got[1].Attrs {
+		keys = append(keys, a.Key+"="+a.Value.String())
+	}
+	if want := []string{"service=api", "req.ms=250", "req.user.id=7"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("attrs: got %v, want %v", keys, want)
+	}
+	if v, ok := got[1].Attr("req.user.id"); !ok || v.Int64() != 7 {
+		t.Errorf("Attr: got %v, %t", v, ok)
+	}
+
+	logged := buf.String()
+	if strings.Contains(logged, "not logged") || !strings.Contains(logged, "msg=slow service=api req.ms=250 req.user.id=7") {
+		t.Errorf("inner handler got %q", logged)
+	}
+}
+
+func TestHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	inner := slog.NewTextHandler(&buf, nil)
+	x := LevelCounterSampler(8)
+	obs.Start(x)
+	logger := slog.New(HandlerMake(inner, x, slog.LevelWarn))
+
+	logger.Info("ignored")
+	logger.Warn("a")
+	logger.Error("b")
+	logger.Error("c")
+
+	want := LevelCounts{slog.LevelWarn: 1, slog.LevelError: 2}
+	if got := obs.StopAndCollect(x); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 4 {
+		t.Errorf("inner handler logged %d records, want all 4", n)
+	}
+}
@@ -0,0 +1,133 @@
+package obs
+
+import "time"
+
+// A watchdog detects stalls in a Sampler's input, and runs its periodic ticks, shard flushes and checkpoints,
+// on behalf of its processing goroutine.
+type watchdog struct {
+	onStall func(since time.Time)
+	clock   Clock
+	timeout time.Duration
+	ticker  Ticker // nil if disabled
+
+	last    time.Time // when the last sample was received
+	stalled bool
+
+	onTick     func()
+	tickTicker Ticker // nil if disabled
+
+	onFlush     func()
+	flushTicker Ticker // nil unless sharded
+
+	onCheckpoint     func()
+	checkpointTicker Ticker // nil unless checkpointing
+}
+
+func watchdogMake[S any, T any](x *Sampler[S, T]) *watchdog {
+	o := &watchdog{}
+	if x.OnTick != nil && x.TickInterval > 0 {
+		o.onTick = func() {
+			x.stateMux.Lock()
+			x.OnTick(x.state)
+			x.stateMux.Unlock()
+		}
+		o.tickTicker = clockOr(x.Clock).NewTicker(x.TickInterval)
+	}
+	if x.checkpointInterval > 0 {
+		o.onCheckpoint = func() {
+			checkpoint(x)
+		}
+		o.checkpointTicker = clockOr(x.Clock).NewTicker(x.checkpointInterval)
+	}
+	if x.OnStall == nil || x.StallTimeout <= 0 {
+		return o
+	}
+
+	o.onStall = x.OnStall
+	o.clock = clockOr(x.Clock)
+	o.timeout = x.StallTimeout
+	o.ticker = o.clock.NewTicker(max(x.StallTimeout/2, 1))
+	o.last = o.clock.Now()
+	return o
+}
+
+// received rearms the watchdog after a sample is received.
+func (x *watchdog) received() {
+	if x.ticker != nil {
+		x.last = x.clock.Now()
+		x.stalled = false
+	}
+}
+
+func (x *watchdog) stop() {
+	if x.ticker != nil {
+		x.ticker.Stop()
+	}
+	if x.tickTicker != nil {
+		x.tickTicker.Stop()
+	}
+	if x.flushTicker != nil {
+		x.flushTicker.Stop()
+	}
+	if x.checkpointTicker != nil {
+		x.checkpointTicker.Stop()
+	}
+}
+
+func (x *watchdog) tick() {
+	if !x.stalled && x.clock.Now().Sub(x.last) >= x.timeout {
+		x.stalled = true
+		x.onStall(x.last)
+	}
+}
+
+// ticks returns the channel driving stall checks; nil if disabled.
+func (x *watchdog) ticks() <-chan time.Time {
+	if x.ticker == nil {
+		return nil
+	}
+	return x.ticker.C()
+}
+
+// periodic returns the channel driving OnTick calls; nil if disabled.
+func (x *watchdog) periodic() <-chan time.Time {
+	if x.tickTicker == nil {
+		return nil
+	}
+	return x.tickTicker.C()
+}
+
+// flushes returns the channel driving shard flushes; nil unless sharded.
+func (x *watchdog) flushes() <-chan time.Time {
+	if x.flushTicker == nil {
+		return nil
+	}
+	return x.flushTicker.C()
+}
+
+// checkpoints returns the channel driving checkpoints; nil unless checkpointing.
+func (x *watchdog) checkpoints() <-chan time.Time {
+	if x.checkpointTicker == nil {
+		return nil
+	}
+	return x.checkpointTicker.C()
+}
+
+// receive waits for the next item of the queue, checking for stalls and running ticks in the meantime.
+func receive[T any](ch chan item[T], w *watchdog) (item[T], bool) {
+	for {
+		select {
+		case it, ok := <-ch:
+			w.received()
+			return it, ok
+		case <-w.ticks():
+			w.tick()
+		case <-w.periodic():
+			w.onTick()
+		case <-w.flushes():
+			w.onFlush()
+		case <-w.checkpoints():
+			w.onCheckpoint()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package obs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestOnStall(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := obstest.ClockMake(start)
+	x := obs.SamplerMake(4, func(s *int, v int) { *s += v })
+	x.Clock = clock
+	x.StallTimeout = 10 * time.Second
+	stalls := make(chan time.Time, 4)
+	x.OnStall = func(since time.Time) { stalls <- since }
+	obs.Start(x)
+	obs.Flush(x) // the watchdog is armed once the processing goroutine runs
+
+	wait := func(want time.Time) {
+		t.Helper()
+		select {
+		case since := <-stalls:
+			if !since.Equal(want) {
+				t.Errorf("stalled since %v, want %v", since, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("OnStall not called")
+		}
+	}
+
+	clock.Advance(9 * time.Second)
+	obs.Flush(x)
+	if len(stalls) != 0 {
+		t.Fatal("stalled before the timeout")
+	}
+
+	clock.Advance(10 * time.Second)
+	wait(start.Add(9 * time.Second))
+
+	// fires once per stall, and is rearmed by the next sample
+	clock.Advance(10 * time.Second)
+	obs.Sample(x, 1)
+	obs.Flush(x)
+	clock.Advance(10 * time.Second)
+	wait(start.Add(29 * time.Second))
+
+	obs.StopAndWait(x)
+	if n := len(stalls); n != 0 {
+		t.Errorf("%d extra stalls reported", n)
+	}
+}
+
+func TestOnTick(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.SamplerMake(4, func(s *int, v int) { *s += v })
+	x.Clock = clock
+	x.TickInterval = time.Second
+	ticks := make(chan int, 4)
+	x.OnTick = func(s *int) {
+		ticks <- *s
+		*s = 0 // rotate
+	}
+	obs.Start(x)
+	obs.Sample(x, 2)
+	obs.Sample(x, 3)
+	obs.Flush(x)
+
+	wait := func(want int) {
+		t.Helper()
+		select {
+		case got := <-ticks:
+			if got != want {
+				t.Errorf("got state %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("OnTick not called")
+		}
+	}
+
+	clock.Advance(time.Second)
+	wait(5)
+	// runs even without samples
+	clock.Advance(time.Second)
+	wait(0)
+
+	obs.StopAndWait(x)
+}
@@ -0,0 +1,197 @@
+package obs
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultObjectives are the quantiles estimated by SummarySampler if none are given.
+var DefaultObjectives = []float64{.5, .9, .99}
+
+// SummaryState tracks the count and sum of float64 samples, along with streaming estimates of a set of quantiles.
+// Quantiles are estimated with the P² algorithm, in constant memory per quantile.
+// Add is a sample function, for use with SamplerMakeState.
+//
+// Values[i] is the current estimate of quantile Objectives[i]. The estimators themselves are not exported,
+// so a SummaryState decoded from JSON keeps its Count and Sum, but rebuilds its estimators on the next sample,
+// seeded from its Values once at least five samples had been seen.
+type SummaryState struct {
+	Objectives []float64
+	Values     []float64
+	Count      uint64
+	Sum        float64
+
+	estimators []quantileEstimator
+}
+
+// SummaryStateMake returns an empty summary estimating the given quantiles, which must be in [0, 1].
+func SummaryStateMake(objectives []float64) *SummaryState {
+	o := &SummaryState{
+		Objectives: append([]float64(nil), objectives...),
+	}
+	o.Reset()
+	return o
+}
+
+func (x *SummaryState) Add(v float64) {
+	if len(x.estimators) != len(x.Objectives) {
+		x.rebuild()
+	}
+
+	for i := range x.estimators {
+		x.estimators[i].add(v)
+		x.Values[i] = x.estimators[i].value()
+	}
+	x.Count++
+	x.Sum += v
+}
+
+func (x *SummaryState) Clone() SummaryState {
+	o := *x
+	o.Values = append([]float64(nil), x.Values...)
+	o.estimators = append([]quantileEstimator(nil), x.estimators...)
+	return o
+}
+
+// Quantile returns the current estimate of quantile q, which must be one of the Objectives.
+// Returns false if it isn't, or if there have been no samples yet.
+func (x *SummaryState) Quantile(q float64) (float64, bool) {
+	for i, objective := range x.Objectives {
+		if objective == q && x.Count > 0 {
+			return x.Values[i], true
+		}
+	}
+	return 0, false
+}
+
+// Reset clears the aggregate, keeping the objectives.
+func (x *SummaryState) Reset() {
+	*x = SummaryState{
+		Objectives: x.Objectives,
+		Values:     make([]float64, len(x.Objectives)),
+		estimators: make([]quantileEstimator, len(x.Objectives)),
+	}
+	for i, q := range x.Objectives {
+		x.estimators[i].init(q)
+	}
+}
+
+// rebuild recreates the estimators of a decoded state, keeping its totals.
+func (x *SummaryState) rebuild() {
+	seed := len(x.Values) == len(x.Objectives) && x.Count >= 5
+	if !seed {
+		x.Values = make([]float64, len(x.Objectives))
+	}
+	x.estimators = make([]quantileEstimator, len(x.Objectives))
+	for i, q := range x.Objectives {
+		x.estimators[i].init(q)
+		if seed {
+			x.estimators[i].seed(x.Values[i])
+		}
+	}
+}
+
+// SummarySampler returns a Sampler that aggregates samples into a summary estimating the given quantiles.
+// Uses DefaultObjectives if objectives is nil.
+//
+// Like any Sampler, it is a Loader of its state, in this case a SummaryState.
+func SummarySampler(queueSize int, objectives []float64) *Sampler[SummaryState, float64] {
+	if objectives == nil {
+		objectives = DefaultObjectives
+	}
+	return SamplerMakeState(queueSize, SummaryStateMake(objectives), (*SummaryState).Add)
+}
+
+// quantileEstimator is a P² estimator of a single quantile, tracking five markers.
+// Marker 2 estimates the quantile, markers 0 and 4 are the extremes.
+type quantileEstimator struct {
+	heights   [5]float64
+	positions [5]float64 // actual marker positions
+	desired   [5]float64 // desired marker positions
+	steps     [5]float64 // desired position increments per observation
+	n         int
+}
+
+func (x *quantileEstimator) init(q float64) {
+	*x = quantileEstimator{
+		positions: [5]float64{0, 1, 2, 3, 4},
+		desired:   [5]float64{0, 2 * q, 4 * q, 2 + 2*q, 4},
+		steps:     [5]float64{0, q / 2, q, (1 + q) / 2, 1},
+	}
+}
+
+// seed sets all markers to v, as if five observations had been made that all estimate to v.
+func (x *quantileEstimator) seed(v float64) {
+	x.heights = [5]float64{v, v, v, v, v}
+	x.n = 5
+}
+
+func (x *quantileEstimator) add(v float64) {
+	if x.n < 5 {
+		x.heights[x.n] = v
+		x.n++
+		if x.n == 5 {
+			sort.Float64s(x.heights[:])
+		}
+		return
+	}
+	x.n++
+
+	// find the cell containing v, extending the extremes if needed
+	var k int
+	switch {
+	case v < x.heights[0]:
+		x.heights[0] = v
+	case v >= x.heights[4]:
+		x.heights[4] = v
+		k = 3
+	default:
+		for k = 0; v >= x.heights[k+1]; k++ {
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		x.positions[i]++
+	}
+	for i := range x.desired {
+		x.desired[i] += x.steps[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := x.desired[i] - x.positions[i]
+		if (d < 1 || x.positions[i+1]-x.positions[i] <= 1) && (d > -1 || x.positions[i-1]-x.positions[i] >= -1) {
+			continue
+		}
+
+		sign := math.Copysign(1, d)
+		h := x.parabolic(i, sign)
+		if h <= x.heights[i-1] || h >= x.heights[i+1] {
+			j := i + int(sign)
+			h = x.heights[i] + sign*(x.heights[j]-x.heights[i])/(x.positions[j]-x.positions[i])
+		}
+		x.heights[i] = h
+		x.positions[i] += sign
+	}
+}
+
+// parabolic returns the piecewise parabolic prediction of marker i's height after moving it by d (±1).
+func (x *quantileEstimator) parabolic(i int, d float64) float64 {
+	h, p := x.heights, x.positions
+	return h[i] + d/(p[i+1]-p[i-1])*((p[i]-p[i-1]+d)*(h[i+1]-h[i])/(p[i+1]-p[i])+(p[i+1]-p[i]-d)*(h[i]-h[i-1])/(p[i]-p[i-1]))
+}
+
+// value returns the current estimate.
+// Until five observations have been made, it is the exact quantile of those seen so far.
+func (x *quantileEstimator) value() float64 {
+	if x.n >= 5 {
+		return x.heights[2]
+	}
+	if x.n == 0 {
+		return 0
+	}
+
+	seen := append([]float64(nil), x.heights[:x.n]...)
+	sort.Float64s(seen)
+	q := x.steps[2]
+	return seen[int(q*float64(x.n-1)+0.5)]
+}
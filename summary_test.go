@@ -0,0 +1,73 @@
+package obs
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSummarySampler(t *testing.T) {
+	x := SummarySampler(64, []float64{0.5, 0.9, 0.99})
+	x.Strategy = BlockOnOverflow
+	Start(x)
+	r := rand.New(rand.NewSource(1))
+	for _, i := range r.Perm(10000) {
+		Sample(x, float64(i+1))
+	}
+	s := StopAndCollect(x)
+	if s.Count != 10000 || s.Sum != 10000*10001/2 {
+		t.Errorf("got count %d, sum %v", s.Count, s.Sum)
+	}
+	for _, q := range s.Objectives {
+		got, ok := s.Quantile(q)
+		if want := q * 10000; !ok || math.Abs(got-want) > 0.02*10000 {
+			t.Errorf("quantile %v: got %v, %t; want about %v", q, got, ok, want)
+		}
+	}
+	if _, ok := s.Quantile(0.75); ok {
+		t.Error("quantile outside the objectives: got true")
+	}
+}
+
+func TestSummaryFewSamples(t *testing.T) {
+	s := SummaryStateMake([]float64{0.5})
+	if _, ok := s.Quantile(0.5); ok {
+		t.Error("no samples: got true")
+	}
+	for _, v := range []float64{3, 1, 2} {
+		s.Add(v)
+	}
+	// exact until the estimator has five observations
+	if got, _ := s.Quantile(0.5); got != 2 {
+		t.Errorf("got %v, want 2", got)
+	}
+
+	c := s.Clone()
+	s.Reset()
+	if c.Count != 3 || s.Count != 0 || len(s.Objectives) != 1 {
+		t.Errorf("clone %+v, reset %+v", c, s)
+	}
+	c.Add(4)
+	c.Add(5)
+	if got, _ := c.Quantile(0.5); got != 3 {
+		t.Errorf("clone after more samples: got %v, want 3", got)
+	}
+}
+
+func TestSummaryRestoreState(t *testing.T) {
+	x := SummarySampler(16, []float64{.5})
+	if err := RestoreState(x, []byte(`{"Objectives":[0.5],"Values":[8],"Count":17,"Sum":136}`)); err != nil {
+		t.Fatal(err)
+	}
+	Start(x)
+	Sample(x, 9)
+	state := StopAndCollect(x)
+
+	if state.Count != 18 || state.Sum != 145 {
+		t.Errorf("got count %d, sum %v, want 18, 145", state.Count, state.Sum)
+	}
+	// the estimate resumes from the restored one, rather than from the single new sample
+	if got, _ := state.Quantile(.5); got != 8 {
+		t.Errorf("median: got %v, want 8", got)
+	}
+}
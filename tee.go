@@ -0,0 +1,48 @@
+package obs
+
+// A Consumer accepts samples of type T, like a Sampler of any state type.
+type Consumer[T any] interface {
+	Sample(T)
+}
+
+// A Tee feeds every sample to each of its members in order, so that a single instrumentation point can drive several
+// independent Samplers, each with its own state and queue:
+//
+//	requests := obs.Tee[time.Duration]{obs.LatencySampler(1024, nil), eventLog}
+//	requests.Start()
+//	defer requests.Stop()
+//
+//	requests.Sample(elapsed)
+//
+// Members may themselves be Tees. Each member applies its own overflow behavior, so one may discard a sample that the
+// others accept.
+type Tee[T any] []Consumer[T]
+
+func (x Tee[T]) Sample(v T) {
+	for _, c := range x {
+		c.Sample(v)
+	}
+}
+
+// Start starts all members that can be started, such as Samplers and other Tees.
+func (x Tee[T]) Start() {
+	for _, c := range x {
+		if s, ok := c.(lifecycle); ok {
+			s.Start()
+		}
+	}
+}
+
+// Stop stops all members that can be stopped, such as Samplers and other Tees.
+func (x Tee[T]) Stop() {
+	for _, c := range x {
+		if s, ok := c.(lifecycle); ok {
+			s.Stop()
+		}
+	}
+}
+
+type lifecycle interface {
+	Start()
+	Stop()
+}
@@ -0,0 +1,37 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// recording is a Consumer without a lifecycle.
+type recording []int
+
+func (x *recording) Sample(v int) {
+	*x = append(*x, v)
+}
+
+func TestTee(t *testing.T) {
+	sum := summing(4)
+	peak := SamplerMake(4, func(s *int, v int) { *s = max(*s, v) })
+	var log recording
+	x := Tee[int]{sum, Tee[int]{peak, &log}}
+	x.Start()
+	for _, v := range []int{3, 1, 4} {
+		x.Sample(v)
+	}
+	x.Stop()
+	<-sum.Done()
+	<-peak.Done()
+
+	if got := Snapshot(sum); got != 8 {
+		t.Errorf("sum: got %d, want 8", got)
+	}
+	if got := Snapshot(peak); got != 4 {
+		t.Errorf("nested peak: got %d, want 4", got)
+	}
+	if want := (recording{3, 1, 4}); !reflect.DeepEqual(log, want) {
+		t.Errorf("recording: got %v, want %v", log, want)
+	}
+}
@@ -0,0 +1,64 @@
+package obs_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestThrottledHandler(t *testing.T) {
+	var calls atomic.Int32
+	expensive := obs.LoaderFunc[int](func() int {
+		n := calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return int(n)
+	})
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	h := obs.ThrottledHandler(obs.MapOf(obs.Value{Label: "scan", Loader: expensive}), time.Second)
+	h.Clock = clock
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	scrape := func(n int, want string) {
+		t.Helper()
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := http.Get(srv.URL + "?format=prometheus")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				if !strings.Contains(string(body), want) {
+					t.Errorf("got %q, want it to contain %q", body, want)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	scrape(20, "scan 1\n")
+	clock.Advance(500 * time.Millisecond)
+	scrape(20, "scan 1\n")
+	if n := calls.Load(); n != 1 {
+		t.Errorf("within the interval: %d loads, want 1", n)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	scrape(20, "scan 2\n")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("after the interval: %d loads, want 2", n)
+	}
+}
@@ -0,0 +1,88 @@
+package obs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+// constant returns a Loader of v.
+func constant(v any) obs.Loader {
+	return obs.LoaderFunc[any](func() any { return v })
+}
+
+func TestMapTimed(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	m := obs.MapMakeTimed()
+	m.Clock = clock
+
+	m.Set("a", obs.Value{Label: "a", Loader: constant(1)})
+	clock.Advance(time.Minute)
+	m.Set("b", obs.Value{Label: "b", Loader: constant(2)})
+	m.Set("c", obs.Value{Label: "c", Loader: constant(3)})
+
+	if at, ok := m.LastSet("a"); !ok || !at.Equal(time.Unix(0, 0)) {
+		t.Errorf("LastSet(a): got %v, %v", at, ok)
+	}
+	if at, ok := m.LastSet("b"); !ok || !at.Equal(time.Unix(60, 0)) {
+		t.Errorf("LastSet(b): got %v, %v", at, ok)
+	}
+	if _, ok := m.LastSet("d"); ok {
+		t.Error("LastSet of an absent key")
+	}
+
+	clock.Advance(30 * time.Second)
+	if n := m.DeleteStale(time.Minute); n != 1 {
+		t.Errorf("deleted %d stale members, want 1", n)
+	}
+	if _, ok := m.Get("a"); ok {
+		t.Error("stale member kept")
+	}
+	if _, ok := m.Get("b"); !ok {
+		t.Error("fresh member deleted")
+	}
+
+	clock.Advance(time.Hour)
+	if n := m.DeleteStale(time.Minute); n != 2 {
+		t.Errorf("bulk deletion: got %d, want 2", n)
+	}
+
+	if n := obs.MapMake().DeleteStale(0); n != 0 {
+		t.Errorf("untimed Map: deleted %d", n)
+	}
+}
+
+func TestMapExpiring(t *testing.T) {
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	m := obs.MapMakeExpiring(time.Minute, 10*time.Second)
+	m.Clock = clock
+	defer obs.CloseMap(m)
+
+	m.Set("old", obs.Value{Label: "old", Loader: constant(1)})
+	clock.Advance(40 * time.Second)
+	m.Set("fresh", obs.Value{Label: "fresh", Loader: constant(2)})
+	clock.Advance(30 * time.Second)
+
+	// the reaper runs in the background on the next tick
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, ok := m.Get("old"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired member not reaped")
+		}
+	}
+	if _, ok := m.Get("fresh"); !ok {
+		t.Error("fresh member reaped")
+	}
+
+	obs.CloseMap(m)
+	obs.CloseMap(m)
+	clock.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := m.Get("fresh"); !ok {
+		t.Error("member reaped after CloseMap")
+	}
+}
@@ -0,0 +1,33 @@
+package obs
+
+import "time"
+
+// A Timer measures the duration of an operation, and submits it as a sample when stopped:
+//
+//	t := obs.TimerStart(latencies)
+//	defer t.Stop()
+//
+// The zero value is not usable.
+type Timer struct {
+	c     Consumer[time.Duration]
+	start time.Time
+}
+
+// TimerStart returns a Timer started now, which will submit its duration to c.
+func TimerStart(c Consumer[time.Duration]) Timer {
+	return Timer{c, time.Now()}
+}
+
+// Stop submits the time elapsed since the Timer was started, and returns it.
+// Every call submits a new sample.
+func (x Timer) Stop() time.Duration {
+	d := time.Since(x.start)
+	x.c.Sample(d)
+	return d
+}
+
+// Time calls fn, and submits its duration to c, even if it panics.
+func Time(c Consumer[time.Duration], fn func()) {
+	defer TimerStart(c).Stop()
+	fn()
+}
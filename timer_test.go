@@ -0,0 +1,45 @@
+package obs
+
+import (
+	"testing"
+	"time"
+)
+
+// timings is a Consumer recording its samples.
+type timings []time.Duration
+
+func (x *timings) Sample(d time.Duration) {
+	*x = append(*x, d)
+}
+
+func TestTimer(t *testing.T) {
+	var got timings
+	x := TimerStart(&got)
+	time.Sleep(time.Millisecond)
+	d := x.Stop()
+	if len(got) != 1 || got[0] != d || d < time.Millisecond {
+		t.Errorf("got %v, returned %v", got, d)
+	}
+	if x.Stop(); len(got) != 2 || got[1] < got[0] {
+		t.Errorf("second Stop: got %v", got)
+	}
+}
+
+func TestTimePanic(t *testing.T) {
+	var got timings
+	func() {
+		defer func() { recover() }()
+		Time(&got, func() { panic("boom") })
+	}()
+	if len(got) != 1 {
+		t.Errorf("got %d samples, want 1 despite the panic", len(got))
+	}
+
+	// through a Sampler
+	x := SamplerMake(1, func(s *int, d time.Duration) { *s++ })
+	Start(x)
+	Time(x, func() {})
+	if n := StopAndCollect(x); n != 1 {
+		t.Errorf("Sampler: got %d samples, want 1", n)
+	}
+}
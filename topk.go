@@ -0,0 +1,126 @@
+package obs
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// A TopK tracks the most frequent values in a stream, using the Space-Saving algorithm with bounded memory:
+// it monitors a fixed number of values (4k), and when a new value arrives with all slots taken, it replaces
+// the least frequent one, inheriting its count.
+//
+// Counts are therefore approximate: a value's count may be overestimated by up to its Error (the count it inherited),
+// which is at most the number of added values divided by the number of slots. Values frequent enough to matter are
+// reliably retained, while the tail of the ranking is less accurate.
+//
+// The zero value tracks a single value; use TopKMake for more.
+type TopK[T comparable] struct {
+	k       int
+	entries []Ranked[T]
+	index   map[T]int // position of each monitored value in entries
+}
+
+// Ranked is a value along with its approximate count.
+type Ranked[T any] struct {
+	Value T
+	Count uint64
+	Error uint64 // upper bound on the count overestimation
+}
+
+func TopKMake[T comparable](k int) *TopK[T] {
+	k = max(k, 1)
+	return &TopK[T]{
+		k:       k,
+		entries: make([]Ranked[T], 0, 4*k),
+		index:   make(map[T]int, 4*k),
+	}
+}
+
+// TopKSampler returns a Sampler that tracks its k most frequent samples.
+//
+// Like any Sampler, it is a Loader of its state, in this case a TopK, which encodes to JSON as its Top ranking
+// and can be restored from it.
+func TopKSampler[T comparable](queueSize, k int) *Sampler[TopK[T], T] {
+	return SamplerMakeState(queueSize, TopKMake[T](k), (*TopK[T]).Add)
+}
+
+func (x *TopK[T]) Add(v T) {
+	if x.index == nil {
+		// zero value
+		x.Reset()
+	}
+	if i, ok := x.index[v]; ok {
+		x.entries[i].Count++
+		return
+	}
+
+	if len(x.entries) < cap(x.entries) {
+		x.index[v] = len(x.entries)
+		x.entries = append(x.entries, Ranked[T]{Value: v, Count: 1})
+		return
+	}
+
+	// evict the least frequent value
+	least := 0
+	for i, e := range x.entries {
+		if e.Count < x.entries[least].Count {
+			least = i
+		}
+	}
+	evicted := x.entries[least]
+	delete(x.index, evicted.Value)
+	x.index[v] = least
+	x.entries[least] = Ranked[T]{Value: v, Count: evicted.Count + 1, Error: evicted.Count}
+}
+
+func (x *TopK[T]) Clone() TopK[T] {
+	o := TopK[T]{
+		k:       x.k,
+		entries: append(make([]Ranked[T], 0, cap(x.entries)), x.entries...),
+		index:   make(map[T]int, len(x.index)),
+	}
+	for k, v := range x.index {
+		o.index[k] = v
+	}
+	return o
+}
+
+// Reset forgets all values, keeping k.
+func (x *TopK[T]) Reset() {
+	*x = *TopKMake[T](x.k)
+}
+
+// Top returns the (at most) k most frequent values, most frequent first.
+func (x *TopK[T]) Top() []Ranked[T] {
+	o := append([]Ranked[T](nil), x.entries...)
+	sort.Slice(o, func(i, j int) bool {
+		return o[i].Count > o[j].Count
+	})
+	return o[:min(len(o), x.k)]
+}
+
+// MarshalJSON encodes the result of Top, so that TopKSamplers export their ranking.
+func (x TopK[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.Top())
+}
+
+// UnmarshalJSON restores the ranking produced by MarshalJSON, counts and errors included, so that TopKSamplers can be
+// restored from a checkpoint. Only the top k values are encoded, so the rest of the monitored values are forgotten.
+// Keeps k if already set, otherwise takes it from the length of the ranking.
+func (x *TopK[T]) UnmarshalJSON(data []byte) error {
+	var top []Ranked[T]
+	if err := json.Unmarshal(data, &top); err != nil {
+		return err
+	}
+
+	o := TopKMake[T](max(x.k, len(top)))
+	for _, e := range top {
+		if _, ok := o.index[e.Value]; ok {
+			continue
+		}
+		o.index[e.Value] = len(o.entries)
+		o.entries = append(o.entries, e)
+	}
+	*x = *o
+	return nil
+}
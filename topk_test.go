@@ -0,0 +1,73 @@
+package obs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTopKSkewed(t *testing.T) {
+	x := TopKMake[int](3)
+	// values 0, 1 and 2 dominate a long tail of singletons
+	for i := 0; i < 1000; i++ {
+		x.Add(i % 3)
+		x.Add(1000 + i)
+	}
+
+	top := x.Top()
+	if len(top) != 3 {
+		t.Fatalf("got %d values, want 3", len(top))
+	}
+	for _, r := range top {
+		if r.Value > 2 {
+			t.Errorf("tail value %v ranked in top: %+v", r.Value, top)
+		}
+		if r.Count < 333 || r.Count-r.Error > 334 {
+			t.Errorf("count of %v: %d (error %d), want about 333", r.Value, r.Count, r.Error)
+		}
+	}
+}
+
+func TestTopKSamplerJSON(t *testing.T) {
+	x := TopKSampler[string](16, 1)
+	Start(x)
+	for _, v := range []string{"a", "b", "a"} {
+		Sample(x, v)
+	}
+	StopAndWait(x)
+
+	data, err := json.Marshal(x.Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `[{"Value":"a","Count":2,"Error":0}]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestTopKRestoreState(t *testing.T) {
+	x := TopKSampler[string](16, 2)
+	Start(x)
+	for _, v := range []string{"a", "b", "a", "c", "a", "b"} {
+		Sample(x, v)
+	}
+	StopAndWait(x)
+	data, err := MarshalState(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y := TopKSampler[string](16, 2)
+	if err := RestoreState(y, data); err != nil {
+		t.Fatal(err)
+	}
+	Start(y)
+	Sample(y, "a")
+	StopAndWait(y)
+
+	state := Snapshot(y)
+	want := []Ranked[string]{{Value: "a", Count: 4}, {Value: "b", Count: 2}}
+	if got := state.Top(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package obs
+
+// A LoaderOf is a Loader whose values are statically known to be of type V, and can be obtained as such.
+// Counter, Gauge and the Loadables are LoaderOfs of their respective value types.
+type LoaderOf[V any] interface {
+	Loader
+	Value() V
+}
+
+// LoaderFunc adapts a function to the LoaderOf interface.
+type LoaderFunc[V any] func() V
+
+func (x LoaderFunc[V]) Load() any {
+	return x()
+}
+
+func (x LoaderFunc[V]) Value() V {
+	return x()
+}
+
+// A TypedMap is a Map with keys of type K, whose members all provide values of type V,
+// sparing its users type assertions.
+//
+// Its methods are concurrent safe. Child Maps of the underlying Map are not visible through it.
+type TypedMap[K comparable, V any] struct {
+	m *Map
+}
+
+func TypedMapMake[K comparable, V any]() *TypedMap[K, V] {
+	return &TypedMap[K, V]{
+		m: MapMake(),
+	}
+}
+
+func (x *TypedMap[K, V]) Delete(key K) {
+	x.m.Delete(key)
+}
+
+// Get loads the value of the given key.
+// Returns false if the key is absent, or if its Loader panicked.
+func (x *TypedMap[K, V]) Get(key K) (V, bool) {
+	v, ok := x.m.Get(key)
+	if !ok {
+		var o V
+		return o, false
+	}
+	return loadOf[V](v)
+}
+
+// Map returns the underlying Map, for exporting.
+// Members Set through it that are not LoaderOf[V] (or not keyed by K) are invisible to the TypedMap.
+func (x *TypedMap[K, V]) Map() *Map {
+	return x.m
+}
+
+// Range calls the given function with the keys, labels and loaded values of all members.
+// Members whose Loader panics are skipped. The Map is not locked during the calls.
+func (x *TypedMap[K, V]) Range(fn func(key K, label string, value V)) {
+	for k, v := range x.m.load() {
+		key, ok := k.(K)
+		if !ok {
+			continue
+		}
+		if loaded, ok := loadOf[V](v); ok {
+			fn(key, v.Label, loaded)
+		}
+	}
+}
+
+// Set adds or replaces a member. The rest of the Value's fields are left at their zero values; see SetValue.
+func (x *TypedMap[K, V]) Set(key K, label string, loader LoaderOf[V]) {
+	x.m.Set(key, Value{
+		Label:  label,
+		Loader: loader,
+	})
+}
+
+// SetValue is like Set, but takes the metadata of the member from val, whose Loader is replaced by the given one.
+func (x *TypedMap[K, V]) SetValue(key K, val Value, loader LoaderOf[V]) {
+	val.Loader = loader
+	x.m.Set(key, val)
+}
+
+// loadOf obtains the typed value of a member, recovering from a panicking Loader.
+// Returns false if the Loader is not a LoaderOf[V], or if it panicked.
+func loadOf[V any](v Value) (o V, ok bool) {
+	l, ok := v.Loader.(LoaderOf[V])
+	if !ok {
+		return o, false
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			warn("obs: Loader panicked", "label", v.Label, "panic", r)
+			ok = false
+		}
+	}()
+	return l.Value(), true
+}
@@ -0,0 +1,43 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypedMap(t *testing.T) {
+	captureLog(t)
+
+	x := TypedMapMake[string, float64]()
+	var g Gauge
+	g.Set(1.5)
+	x.Set("load", "cpu.load", &g)
+	x.SetValue("temp", Value{Label: "temperature", Unit: "celsius"}, LoaderFunc[float64](func() float64 { return 20 }))
+	x.Set("broken", "broken", LoaderFunc[float64](func() float64 { panic("boom") }))
+	x.Map().Set("untyped", Value{Label: "untyped", Loader: constant("x")})
+
+	if v, ok := x.Get("load"); !ok || v != 1.5 {
+		t.Errorf("Get: got %v, %t", v, ok)
+	}
+	for _, key := range []string{"broken", "untyped", "absent"} {
+		if _, ok := x.Get(key); ok {
+			t.Errorf("Get %s: got true", key)
+		}
+	}
+
+	got := make(map[string]float64)
+	x.Range(func(key, label string, v float64) {
+		got[key+" "+label] = v
+	})
+	if want := map[string]float64{"load cpu.load": 1.5, "temp temperature": 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Range: got %v, want %v", got, want)
+	}
+
+	if v, _ := x.Map().Get("temp"); v.Unit != "celsius" {
+		t.Errorf("SetValue metadata: got %+v", v)
+	}
+	x.Delete("load")
+	if _, ok := x.Get("load"); ok {
+		t.Error("after Delete: got true")
+	}
+}
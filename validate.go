@@ -0,0 +1,43 @@
+package obs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrometheusValid checks that a label is a valid Prometheus metric name, without needing sanitization.
+func PrometheusValid(label string) error {
+	if label == "" {
+		return fmt.Errorf("empty metric name")
+	}
+	for i, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return fmt.Errorf("invalid character %q in metric name %q", r, label)
+		}
+	}
+	return nil
+}
+
+// GraphiteValid checks that a label is a valid Graphite metric path:
+// non-empty, dot separated nodes consisting of letters, digits, underscores and dashes.
+func GraphiteValid(label string) error {
+	if label == "" {
+		return fmt.Errorf("empty metric path")
+	}
+	for _, node := range strings.Split(label, ".") {
+		if node == "" {
+			return fmt.Errorf("empty node in metric path %q", label)
+		}
+		for _, r := range node {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			default:
+				return fmt.Errorf("invalid character %q in metric path %q", r, label)
+			}
+		}
+	}
+	return nil
+}
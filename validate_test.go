@@ -0,0 +1,45 @@
+package obs
+
+import (
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	for _, c := range []struct {
+		validate func(string) error
+		label    string
+		ok       bool
+	}{
+		{PrometheusValid, "http_requests_total", true},
+		{PrometheusValid, "ns:metric2", true},
+		{PrometheusValid, "", false},
+		{PrometheusValid, "2xx", false},
+		{PrometheusValid, "http.requests", false},
+		{PrometheusValid, "latency-ms", false},
+		{GraphiteValid, "servers.web-1.cpu_load", true},
+		{GraphiteValid, "cpu", true},
+		{GraphiteValid, "", false},
+		{GraphiteValid, "servers..cpu", false},
+		{GraphiteValid, "servers.cpu.", false},
+		{GraphiteValid, "servers.cpu load", false},
+	} {
+		if err := c.validate(c.label); (err == nil) != c.ok {
+			t.Errorf("%q: got %v, want valid %v", c.label, err, c.ok)
+		}
+	}
+}
+
+func TestMapMakeStrict(t *testing.T) {
+	m := MapMakeStrict(PrometheusValid)
+	m.Set("ok", Value{Label: "requests_total", Loader: constant(1)})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("invalid label accepted")
+		}
+		if _, ok := m.Get("bad"); ok {
+			t.Error("invalid member added")
+		}
+	}()
+	m.Set("bad", Value{Label: "requests.total", Loader: constant(1)})
+}
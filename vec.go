@@ -0,0 +1,176 @@
+package obs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A ValueVec is a family of Values sharing a label, told apart by a tuple of tag values (e.g. per endpoint counters).
+// Members are created on first use, and registered in a Map with the tag names and values of their tuple as Tags,
+// on top of any constant Tags of the family's template, which exporters render in their native label syntax.
+//
+// Its methods are concurrent safe.
+type ValueVec[V Loader] struct {
+	m        *Map
+	template Value
+	names    []string
+	newFunc  func() V
+
+	members map[string]V
+	mux     sync.Mutex
+}
+
+// ValueVecMake returns a ValueVec whose members are created by newFunc, and registered in m as copies of template
+// (minus its Loader), tagged by the given names.
+func ValueVecMake[V Loader](m *Map, template Value, names []string, newFunc func() V) *ValueVec[V] {
+	return &ValueVec[V]{
+		m:        m,
+		template: template,
+		names:    append([]string(nil), names...),
+		newFunc:  newFunc,
+		members:  make(map[string]V),
+	}
+}
+
+// Delete removes the member of the given tag values, if any.
+// Panics if the number of values doesn't match the number of tag names.
+func (x *ValueVec[V]) Delete(values ...string) {
+	key := vecKey(x.names, values)
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if _, ok := x.members[key]; ok {
+		delete(x.members, key)
+		x.m.Delete(vecMapKey{x, key})
+	}
+}
+
+// WithLabels returns the member of the given tag values, in the order of the tag names, creating it if needed.
+// Panics if the number of values doesn't match the number of tag names.
+func (x *ValueVec[V]) WithLabels(values ...string) V {
+	key := vecKey(x.names, values)
+
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	if v, ok := x.members[key]; ok {
+		return v
+	}
+	v := x.newFunc()
+	x.members[key] = v
+	x.m.Set(vecMapKey{x, key}, vecValue(x.template, v, x.names, values))
+	return v
+}
+
+// A SamplerVec is a KeyedSampler of sorts, keyed by tuples of tag values: it maintains a separate state for every tuple,
+// created on the first sample for it, and registered in a Map as a member of a family, as by ValueVec.
+// The registered Values load copies of their states.
+// The usual Sampler functions apply to its embedded Sampler.
+type SamplerVec[V any, S any] struct {
+	*Sampler[map[string]*S, Keyed[string, V]]
+	names []string
+}
+
+// SamplerVecMake returns a SamplerVec whose states are registered in m as copies of template (minus its Loader),
+// tagged by the given names.
+func SamplerVecMake[V any, S any](queueSize int, m *Map, template Value, names []string, sampleFunc func(*S, V)) *SamplerVec[V, S] {
+	x := &SamplerVec[V, S]{
+		names: append([]string(nil), names...),
+	}
+	x.Sampler = SamplerMake(queueSize, func(s *map[string]*S, v Keyed[string, V]) {
+		if *s == nil {
+			*s = make(map[string]*S)
+		}
+
+		state, ok := (*s)[v.Key]
+		if !ok {
+			state = new(S)
+			(*s)[v.Key] = state
+			m.Set(vecMapKey{x, v.Key}, vecValue(template, vecState[V, S]{x, v.Key}, x.names, strings.Split(v.Key, vecSeparator)))
+		}
+		sampleFunc(state, v.Value)
+	})
+	return x
+}
+
+// Load returns a (shallow) copy of the per tuple states, as a map[string]any keyed by the tuples' tags
+// in OpenMetrics label syntax (e.g. `{endpoint="/login"}`).
+func (x *SamplerVec[V, S]) Load() any {
+	x.stateMux.Lock()
+	o := make(map[string]any, len(*x.state))
+	for k, v := range *x.state {
+		o[openMetricsLabels(vecTags(nil, x.names, strings.Split(k, vecSeparator)))] = *v
+	}
+	x.stateMux.Unlock()
+	return o
+}
+
+// WithLabels returns a Consumer pushing samples for the given tag values, in the order of the tag names.
+// Panics if the number of values doesn't match the number of tag names.
+func (x *SamplerVec[V, S]) WithLabels(values ...string) Consumer[V] {
+	return vecConsumer[V, S]{x, vecKey(x.names, values)}
+}
+
+type vecConsumer[V any, S any] struct {
+	x   *SamplerVec[V, S]
+	key string
+}
+
+func (x vecConsumer[V, S]) Sample(v V) {
+	Sample(x.x.Sampler, Keyed[string, V]{x.key, v})
+}
+
+// A vecState loads a copy of one of the states of a SamplerVec.
+type vecState[V any, S any] struct {
+	x   *SamplerVec[V, S]
+	key string
+}
+
+func (x vecState[V, S]) Load() any {
+	x.x.stateMux.Lock()
+	defer x.x.stateMux.Unlock()
+
+	if s, ok := (*x.x.state)[x.key]; ok {
+		return *s
+	}
+	var o S
+	return o
+}
+
+// vecSeparator joins tag values into tuple keys. It is not valid UTF-8, so can't be mistaken for part of a value.
+const vecSeparator = "\xff"
+
+// vecMapKey keys the members of a family in a Map.
+type vecMapKey struct {
+	vec any
+	key string
+}
+
+// vecKey returns the tuple key of the given tag values.
+func vecKey(names, values []string) string {
+	if len(values) != len(names) {
+		panic(fmt.Sprintf("obs: %d tag values given for %d tag names", len(values), len(names)))
+	}
+	return strings.Join(values, vecSeparator)
+}
+
+// vecValue returns a family member, carrying the template's metadata and the tags of its tuple.
+func vecValue(template Value, loader Loader, names, values []string) Value {
+	o := template
+	o.Loader = loader
+	o.Tags = vecTags(template.Tags, names, values)
+	return o
+}
+
+func vecTags(base map[string]string, names, values []string) map[string]string {
+	o := make(map[string]string, len(base)+len(names))
+	for k, v := range base {
+		o[k] = v
+	}
+	for i, name := range names {
+		o[name] = values[i]
+	}
+	return o
+}
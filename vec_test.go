@@ -0,0 +1,101 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func vecMap() (*Map, *ValueVec[*Counter]) {
+	m := MapMake()
+	x := ValueVecMake(m, Value{Label: "requests"}, []string{"code"}, func() *Counter { return &Counter{} })
+	x.WithLabels("200").Add(3)
+	x.WithLabels("500").Inc()
+	return m, x
+}
+
+func TestValueVecRangeTagged(t *testing.T) {
+	m, _ := vecMap()
+	got := make(map[string]any)
+	m.RangeTagged(func(label string, tags map[string]string, v any) {
+		if label != "requests" {
+			t.Errorf("unexpected label %q", label)
+		}
+		got[tags["code"]] = v
+	})
+	if want := map[string]any{"200": int64(3), "500": int64(1)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValueVecJSON(t *testing.T) {
+	m, x := vecMap()
+
+	data, err := m.SnapshotJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"requests{code=\"200\"}":3,"requests{code=\"500\"}":1}`; got != want {
+		t.Errorf("SnapshotJSON: got %s, want %s", got, want)
+	}
+
+	var b bytes.Buffer
+	if err := StreamJSON(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"requests{code=\"500\"}"`) || !strings.Contains(b.String(), `"requests{code=\"200\"}"`) {
+		t.Errorf("StreamJSON lost family members: %s", b.String())
+	}
+
+	x.Delete("500")
+	if data, _ := m.SnapshotJSON(); strings.Contains(string(data), "500") {
+		t.Errorf("deleted member still exported: %s", data)
+	}
+}
+
+func TestValueVecSinkRoundTrip(t *testing.T) {
+	m, _ := vecMap()
+	var b bytes.Buffer
+	if err := OpenMetricsSink(&b).Write(m.labeled()); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`requests{code="200"} 3`, `requests{code="500"} 1`} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("missing %q in:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestParseTaggedLabel(t *testing.T) {
+	tags := map[string]string{"a": `x"y`, "b": "1\n2", "c": `\`}
+	label, got := parseTaggedLabel(taggedLabel("m", tags))
+	if label != "m" || !reflect.DeepEqual(got, tags) {
+		t.Errorf("got %q %v, want m %v", label, got, tags)
+	}
+
+	for _, key := range []string{"plain", "bad{", "bad{a}", `bad{a="1"x}`} {
+		if label, tags := parseTaggedLabel(key); label != key || tags != nil {
+			t.Errorf("%q: got %q %v", key, label, tags)
+		}
+	}
+}
+
+func TestSamplerVec(t *testing.T) {
+	m := MapMake()
+	x := SamplerVecMake(16, m, Value{Label: "latency"}, []string{"endpoint"}, Sum[int])
+	Start(x.Sampler)
+	x.WithLabels("/a").Sample(1)
+	x.WithLabels("/a").Sample(2)
+	x.WithLabels("/b").Sample(5)
+	StopAndWait(x.Sampler)
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"latency{endpoint=\"/a\"}":3,"latency{endpoint=\"/b\"}":5}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
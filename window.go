@@ -0,0 +1,28 @@
+package obs
+
+// windows launches the goroutine closing the Sampler's tumbling windows.
+// The returned function stops it, then closes the current window as partial, leaving the state for Final.
+func windows[S any, T any](x *Sampler[S, T]) (stop func()) {
+	ticker := clockOr(x.Clock).NewTicker(x.Window)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C():
+				x.OnWindow(SnapshotReset(x), false)
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-exited
+		x.OnWindow(Snapshot(x), true)
+	}
+}
@@ -0,0 +1,79 @@
+package obs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blitz-frost/obs"
+	"github.com/blitz-frost/obs/obstest"
+)
+
+func TestWindowPartialFlush(t *testing.T) {
+	type window struct {
+		sum     int
+		partial bool
+	}
+	var windows []window
+	var final int
+
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.SamplerMake(16, obs.Sum[int])
+	x.Clock = clock
+	x.Window = time.Minute
+	x.OnWindow = func(s int, partial bool) {
+		windows = append(windows, window{s, partial})
+	}
+	x.Final = func(s *int) {
+		final = *s
+	}
+
+	obs.Start(x)
+	obs.Sample(x, 2)
+	obs.Sample(x, 3)
+	got := obs.StopAndCollect(x)
+
+	if len(windows) != 1 || windows[0] != (window{5, true}) {
+		t.Errorf("windows: got %+v, want a single partial window of 5", windows)
+	}
+	if final != 5 || got != 5 {
+		t.Errorf("Final saw %d and StopAndCollect returned %d, want 5", final, got)
+	}
+}
+
+func TestWindowBoundaries(t *testing.T) {
+	windows := make(chan int, 4)
+	clock := obstest.ClockMake(time.Unix(0, 0))
+	x := obs.SamplerMake(16, obs.Sum[int])
+	x.Clock = clock
+	x.Window = time.Minute
+	x.OnWindow = func(s int, partial bool) {
+		if !partial {
+			windows <- s
+		}
+	}
+
+	obs.Start(x)
+	defer obs.Stop(x)
+	obs.Sample(x, 2)
+	obs.Sample(x, 3)
+	obs.Flush(x)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case s := <-windows:
+		t.Fatalf("window of %d closed early", s)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	if s := <-windows; s != 5 {
+		t.Errorf("first window: got %d, want 5", s)
+	}
+
+	obs.Sample(x, 4)
+	obs.Flush(x)
+	clock.Advance(time.Minute)
+	if s := <-windows; s != 4 {
+		t.Errorf("second window: got %d, want only its own samples", s)
+	}
+}